@@ -1,14 +1,20 @@
 package main
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"time"
 
+	"github.com/babyfaceeasy/repo-scanner/internal/baseline"
+	"github.com/babyfaceeasy/repo-scanner/internal/cache"
 	"github.com/babyfaceeasy/repo-scanner/internal/config"
 	"github.com/babyfaceeasy/repo-scanner/internal/env"
 	"github.com/babyfaceeasy/repo-scanner/internal/github"
 	"github.com/babyfaceeasy/repo-scanner/internal/output"
+	"github.com/babyfaceeasy/repo-scanner/internal/policy"
+	"github.com/babyfaceeasy/repo-scanner/internal/progress"
 	"github.com/babyfaceeasy/repo-scanner/internal/retry"
 	"github.com/babyfaceeasy/repo-scanner/internal/scanner"
 	"github.com/babyfaceeasy/repo-scanner/internal/service"
@@ -17,11 +23,18 @@ import (
 	"go.uber.org/zap"
 )
 
+// exit codes returned by the scan command
+const (
+	exitToolError       = 1
+	exitPolicyViolation = 2
+)
+
 func main() {
-	// load environment variables
-	cfg, err := env.Load()
+	// load environment variables; the token is checked by the commands that call GitHub
+	cfg, err := env.LoadWithoutToken()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Failed to load environment variables: %v\n", err)
+		os.Exit(1)
 	}
 
 	// initialize logger
@@ -36,14 +49,53 @@ func main() {
 		Short: "A CLI tool to scan GitHub repositories for large files",
 	}
 
+	var scanOpts service.ScanOptions
+	var noCache bool
+
 	scanCmd := &cobra.Command{
 		Use:   "scan [json-config]",
 		Short: "Scan a repository for files larger than a specified size",
 		Args:  cobra.ExactArgs(1),
 		Run: func(cmd *cobra.Command, args []string) {
-			githubClient := github.NewClient(cfg.GitHubToken, log)
+			if cfg.GitHubToken == "" {
+				log.Error("GITHUB_TOKEN is required to scan repositories")
+				os.Exit(exitToolError)
+			}
+			limiter := github.NewRateLimiter(cfg.RateLimitThreshold, log)
+			transport, err := github.NewTransport(github.TransportConfig{
+				ConnectTimeout:        cfg.ConnectTimeout,
+				TLSHandshakeTimeout:   cfg.TLSHandshakeTimeout,
+				ResponseHeaderTimeout: cfg.ResponseHeaderTimeout,
+				IdleConnTimeout:       cfg.IdleConnTimeout,
+				ReadTimeout:           cfg.ReadTimeout,
+				HTTPSProxy:            cfg.HTTPSProxy,
+				HTTPProxy:             cfg.HTTPProxy,
+				NoProxy:               cfg.NoProxy,
+				CAFile:                cfg.CAFile,
+				ClientCertFile:        cfg.ClientCertFile,
+				ClientKeyFile:         cfg.ClientKeyFile,
+			})
+			if err != nil {
+				log.Error("Invalid HTTP transport settings", zap.Error(err))
+				os.Exit(exitToolError)
+			}
+			clientOpts := []github.Option{
+				github.WithTransport(transport),
+				github.WithRateLimiter(limiter),
+				github.WithProgress(progress.New(log)),
+			}
+			if !noCache {
+				tarballCache, err := openCache(cfg, log)
+				if err != nil {
+					log.Warn("Tarball cache unavailable, downloading without it", "error", err)
+				} else {
+					clientOpts = append(clientOpts, github.WithCache(tarballCache))
+				}
+			}
+			githubClient := github.NewClient(cfg.GitHubToken, log, clientOpts...)
 			// TODO: the variables been passed here can be converted to env variables.
-			retryClient := retry.NewRetrier(githubClient, log, 3, 1*time.Second, 15*time.Second)
+			breaker := retry.NewCircuitBreaker(githubClient, log, 0.5, 10, 30*time.Second)
+			retryClient := retry.NewRetrier(breaker, log, 3, 1*time.Second, 15*time.Second)
 			svc := service.New(
 				config.New(),
 				retryClient,
@@ -52,16 +104,111 @@ func main() {
 				log,
 			)
 
-			if err := svc.Scan(args[0]); err != nil {
+			if err := svc.ScanWithOptions(args[0], scanOpts); err != nil {
+				var violationErr *policy.ViolationError
+				if errors.As(err, &violationErr) {
+					log.Warn("Policy violated", "violations", len(violationErr.Violations), "error", violationErr.Error())
+					os.Exit(exitPolicyViolation)
+				}
 				log.Error("Scan failed", zap.Error(err))
-				os.Exit(1)
+				os.Exit(exitToolError)
 			}
 		},
 	}
+	scanCmd.Flags().StringVar(&scanOpts.BaselinePath, "baseline", "", "baseline file to compare against; only new, grown or shrunk files are reported")
+	scanCmd.Flags().BoolVar(&scanOpts.WriteBaseline, "write-baseline", false, "write the scan result to the baseline file (default "+baseline.DefaultPath+")")
+
+	scanCmd.Flags().BoolVar(&noCache, "no-cache", false, "always download the tarball instead of reusing a cached one")
+
+	cacheCmd := &cobra.Command{
+		Use:   "cache",
+		Short: "Manage the cache of downloaded repository tarballs",
+	}
+	cacheCmd.AddCommand(&cobra.Command{
+		Use:   "list",
+		Short: "List cached tarballs, most recently used first",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			tarballCache, err := openCache(cfg, log)
+			if err != nil {
+				return err
+			}
+			entries, err := tarballCache.List()
+			if err != nil {
+				return err
+			}
+			return printJSON(entries)
+		},
+	})
+	var pruneSizeMB int
+	pruneCmd := &cobra.Command{
+		Use:   "prune",
+		Short: "Evict the least recently used tarballs until the cache fits in the size limit",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			maxSizeMB := cfg.CacheMaxSizeMB
+			if cmd.Flags().Changed("max-size-mb") {
+				maxSizeMB = pruneSizeMB
+			} else if maxSizeMB == 0 {
+				// a limit of 0 disables eviction rather than asking for an empty cache
+				return fmt.Errorf("CACHE_MAX_SIZE_MB is 0, so the cache has no size limit; pass --max-size-mb to prune it")
+			}
+			tarballCache, err := openCache(cfg, log)
+			if err != nil {
+				return err
+			}
+			evicted, err := tarballCache.Prune(int64(maxSizeMB) * 1024 * 1024)
+			if err != nil {
+				return err
+			}
+			return printJSON(evicted)
+		},
+	}
+	pruneCmd.Flags().IntVar(&pruneSizeMB, "max-size-mb", 0, "size to prune the cache down to (default CACHE_MAX_SIZE_MB)")
+	cacheCmd.AddCommand(pruneCmd)
+	cacheCmd.AddCommand(&cobra.Command{
+		Use:   "clear",
+		Short: "Remove every cached tarball",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			tarballCache, err := openCache(cfg, log)
+			if err != nil {
+				return err
+			}
+			if err := tarballCache.Clear(); err != nil {
+				return err
+			}
+			log.Info("Cache cleared", "dir", tarballCache.Dir())
+			return nil
+		},
+	})
 
 	rootCmd.AddCommand(scanCmd)
+	rootCmd.AddCommand(cacheCmd)
 	if err := rootCmd.Execute(); err != nil {
 		log.Error("Command execution failed", zap.Error(err))
 		os.Exit(1)
 	}
 }
+
+// openCache opens the tarball cache configured by CACHE_DIR and CACHE_MAX_SIZE_MB
+func openCache(cfg *env.Config, log logger.Logger) (*cache.Cache, error) {
+	dir := cfg.CacheDir
+	if dir == "" {
+		var err error
+		if dir, err = cache.DefaultDir(); err != nil {
+			return nil, err
+		}
+	}
+	return cache.New(dir, int64(cfg.CacheMaxSizeMB)*1024*1024, log)
+}
+
+// printJSON writes v to stdout as indented JSON
+func printJSON(v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding output JSON: %w", err)
+	}
+	_, err = fmt.Fprintln(os.Stdout, string(data))
+	return err
+}
@@ -0,0 +1,265 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Separator joins the path of an archive and the path of an entry inside it, as in outer.zip!/inner/path
+const Separator = "!/"
+
+// HeadLen is the number of leading bytes of each entry passed to the walk function
+const HeadLen = 4096
+
+// Limits protect against archive bombs. Zero values disable a limit.
+type Limits struct {
+	MaxDepth     int     // Levels of nested archives to open, the outermost being 1
+	MaxEntries   int     // Entries read across an archive and everything nested in it
+	MaxTotalSize int64   // Uncompressed bytes read across an archive and everything nested in it
+	MaxRatio     float64 // Highest uncompressed to compressed size ratio declared by a zip entry
+}
+
+// DefaultLimits are sensible limits for archives committed to a repository
+var DefaultLimits = Limits{
+	MaxDepth:     3,
+	MaxEntries:   100000,
+	MaxTotalSize: 2 << 30,
+	MaxRatio:     200,
+}
+
+// ratioMinSize is the declared size below which the ratio limit is not applied,
+// since small runs of repeated bytes compress extremely well without being a threat
+const ratioMinSize = 1 << 20
+
+// LimitError is returned when an archive exceeds one of its Limits
+type LimitError struct {
+	Archive string
+	Reason  string
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("archive %s: %s", e.Archive, e.Reason)
+}
+
+// Entry is a regular file inside an archive
+type Entry struct {
+	Path string // Path inside the outermost archive, nested archives joined with Separator
+	Size int64  // Uncompressed size in bytes
+	Head []byte // Up to HeadLen leading bytes of the contents
+}
+
+type format int
+
+const (
+	formatNone format = iota
+	formatZip
+	formatTar
+	formatTarGz
+	formatTarBz2
+	formatTarZst
+)
+
+var suffixes = []struct {
+	suffix string
+	format format
+}{
+	{".zip", formatZip}, {".jar", formatZip}, {".war", formatZip}, {".ear", formatZip},
+	{".apk", formatZip}, {".aar", formatZip}, {".whl", formatZip}, {".nupkg", formatZip},
+	{".tar", formatTar},
+	{".tar.gz", formatTarGz}, {".tgz", formatTarGz},
+	{".tar.bz2", formatTarBz2}, {".tbz2", formatTarBz2}, {".tbz", formatTarBz2},
+	{".tar.zst", formatTarZst}, {".tzst", formatTarZst},
+}
+
+func formatOf(name string) format {
+	name = strings.ToLower(name)
+	for _, s := range suffixes {
+		if strings.HasSuffix(name, s.suffix) {
+			return s.format
+		}
+	}
+	return formatNone
+}
+
+// IsArchive reports whether name has the extension of a supported archive format
+func IsArchive(name string) bool {
+	return formatOf(name) != formatNone
+}
+
+// Walk calls fn for every regular file in the archive at filePath, descending into nested
+// archives up to limits.MaxDepth. The format is chosen from the extension of name.
+// A *LimitError is returned as soon as a limit is exceeded.
+func Walk(filePath, name string, limits Limits, fn func(Entry) error) error {
+	w := &walker{limits: limits, fn: fn, archive: name}
+	return w.walkFile(filePath, name, "", 1)
+}
+
+type walker struct {
+	limits  Limits
+	fn      func(Entry) error
+	archive string
+	read    int64
+	entries int
+}
+
+func (w *walker) walkFile(filePath, name, prefix string, depth int) error {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	switch formatOf(name) {
+	case formatZip:
+		info, err := f.Stat()
+		if err != nil {
+			return err
+		}
+		zr, err := zip.NewReader(f, info.Size())
+		if err != nil {
+			return fmt.Errorf("reading zip %s: %w", name, err)
+		}
+		return w.walkZip(zr, prefix, depth)
+	case formatTar:
+		return w.walkTar(f, name, prefix, depth)
+	case formatTarGz:
+		gzr, err := gzip.NewReader(f)
+		if err != nil {
+			return fmt.Errorf("reading gzip %s: %w", name, err)
+		}
+		defer gzr.Close()
+		return w.walkTar(gzr, name, prefix, depth)
+	case formatTarBz2:
+		return w.walkTar(bzip2.NewReader(f), name, prefix, depth)
+	case formatTarZst:
+		zr, err := zstd.NewReader(f, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return fmt.Errorf("reading zstd %s: %w", name, err)
+		}
+		defer zr.Close()
+		return w.walkTar(zr, name, prefix, depth)
+	}
+	return fmt.Errorf("unsupported archive format: %s", name)
+}
+
+func (w *walker) walkZip(zr *zip.Reader, prefix string, depth int) error {
+	for _, zf := range zr.File {
+		if zf.FileInfo().IsDir() {
+			continue
+		}
+		if w.limits.MaxRatio > 0 && zf.UncompressedSize64 > ratioMinSize {
+			if zf.CompressedSize64 == 0 || float64(zf.UncompressedSize64)/float64(zf.CompressedSize64) > w.limits.MaxRatio {
+				return w.limitError(fmt.Sprintf("entry %s exceeds the compression ratio limit of %g", prefix+zf.Name, w.limits.MaxRatio))
+			}
+		}
+
+		rc, err := zf.Open()
+		if err != nil {
+			return fmt.Errorf("opening %s: %w", prefix+zf.Name, err)
+		}
+		err = w.entry(zf.Name, int64(zf.UncompressedSize64), rc, prefix, depth)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *walker) walkTar(r io.Reader, name, prefix string, depth int) error {
+	tr := tar.NewReader(&countingReader{r: r, w: w})
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			var limitErr *LimitError
+			if errors.As(err, &limitErr) {
+				return err
+			}
+			return fmt.Errorf("reading tar %s: %w", name, err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		if err := w.entry(header.Name, header.Size, tr, prefix, depth); err != nil {
+			return err
+		}
+	}
+}
+
+// entry reports one file and descends into it when it is itself an archive
+func (w *walker) entry(name string, size int64, r io.Reader, prefix string, depth int) error {
+	w.entries++
+	if w.limits.MaxEntries > 0 && w.entries > w.limits.MaxEntries {
+		return w.limitError(fmt.Sprintf("more than %d entries", w.limits.MaxEntries))
+	}
+
+	// zip entries are not read through the tar counter, so count them here
+	if _, ok := r.(*tar.Reader); !ok {
+		r = &countingReader{r: r, w: w}
+	}
+
+	head := make([]byte, HeadLen)
+	n, err := io.ReadFull(r, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return fmt.Errorf("reading %s: %w", prefix+name, err)
+	}
+	head = head[:n]
+
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+	if err := w.fn(Entry{Path: prefix + name, Size: size, Head: head}); err != nil {
+		return err
+	}
+
+	if !IsArchive(name) || (w.limits.MaxDepth > 0 && depth >= w.limits.MaxDepth) {
+		return nil
+	}
+
+	// nested archives are spooled to disk since zip needs random access
+	tmp, err := os.CreateTemp("", "repo-scan-archive-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	if _, err := io.Copy(tmp, io.MultiReader(bytes.NewReader(head), r)); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return w.walkFile(tmp.Name(), name, prefix+name+Separator, depth+1)
+}
+
+func (w *walker) limitError(reason string) error {
+	return &LimitError{Archive: w.archive, Reason: reason}
+}
+
+// countingReader counts the uncompressed bytes read against the total size limit
+type countingReader struct {
+	r io.Reader
+	w *walker
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.w.read += int64(n)
+	if c.w.limits.MaxTotalSize > 0 && c.w.read > c.w.limits.MaxTotalSize {
+		return n, c.w.limitError(fmt.Sprintf("more than %d uncompressed bytes", c.w.limits.MaxTotalSize))
+	}
+	return n, err
+}
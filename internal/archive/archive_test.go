@@ -0,0 +1,198 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestIsArchive(t *testing.T) {
+	tests := map[string]bool{
+		"lib/app.jar":    true,
+		"dist/site.ZIP":  true,
+		"backup.tar.gz":  true,
+		"data.tgz":       true,
+		"data.tar.zst":   true,
+		"image.png":      false,
+		"notes.gz":       false,
+		"archive.tar.xz": false,
+		"zip/readme.txt": false,
+	}
+	for name, want := range tests {
+		if got := IsArchive(name); got != want {
+			t.Errorf("IsArchive(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestWalk(t *testing.T) {
+	inner := tarGz(t, map[string]string{"models/weights.bin": strings.Repeat("w", 3000)})
+	innermost := zipBytes(t, map[string]string{"deep.txt": "deep"})
+	outer := writeZip(t, map[string]string{
+		"README.md":      "# hello",
+		"lib/bundle.tgz": string(inner),
+		"lib/nested.zip": string(zipBytes(t, map[string]string{"again.zip": string(innermost)})),
+		"assets/":        "",
+		"../escape.txt":  "x",
+	})
+
+	tests := []struct {
+		name   string
+		limits Limits
+		want   []string
+	}{
+		{
+			name:   "default limits",
+			limits: DefaultLimits,
+			want: []string{
+				"README.md 7",
+				"escape.txt 1",
+				"lib/bundle.tgz " + strconv.Itoa(len(inner)),
+				"lib/bundle.tgz!/models/weights.bin 3000",
+				"lib/nested.zip!/again.zip " + strconv.Itoa(len(innermost)),
+				"lib/nested.zip!/again.zip!/deep.txt 4",
+				"lib/nested.zip " + strconv.Itoa(len(zipBytes(t, map[string]string{"again.zip": string(innermost)}))),
+			},
+		},
+		{
+			name:   "depth limit",
+			limits: Limits{MaxDepth: 1},
+			want: []string{
+				"README.md 7",
+				"escape.txt 1",
+				"lib/bundle.tgz " + strconv.Itoa(len(inner)),
+				"lib/nested.zip " + strconv.Itoa(len(zipBytes(t, map[string]string{"again.zip": string(innermost)}))),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			err := Walk(outer, "outer.zip", tt.limits, func(e Entry) error {
+				got = append(got, e.Path+" "+strconv.Itoa(int(e.Size)))
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("Walk() error = %v", err)
+			}
+			sort.Strings(got)
+			sort.Strings(tt.want)
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("Walk() entries =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+func TestWalkLimits(t *testing.T) {
+	bomb := writeZip(t, map[string]string{"zeros.bin": strings.Repeat("\x00", 4<<20)})
+	many := writeZip(t, map[string]string{"a": "1", "b": "2", "c": "3"})
+	large := writeTarGz(t, map[string]string{"a.txt": strings.Repeat("a", 10000)})
+
+	tests := []struct {
+		name   string
+		path   string
+		file   string
+		limits Limits
+	}{
+		{name: "compression ratio", path: bomb, file: "bomb.zip", limits: Limits{MaxRatio: 200}},
+		{name: "entries", path: many, file: "many.zip", limits: Limits{MaxEntries: 2}},
+		{name: "total size", path: large, file: "large.tar.gz", limits: Limits{MaxTotalSize: 5000}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Walk(tt.path, tt.file, tt.limits, func(Entry) error { return nil })
+			var limitErr *LimitError
+			if !errors.As(err, &limitErr) {
+				t.Fatalf("Walk() error = %v, want *LimitError", err)
+			}
+			if limitErr.Archive != tt.file {
+				t.Errorf("LimitError.Archive = %q, want %q", limitErr.Archive, tt.file)
+			}
+		})
+	}
+
+	// the same archives pass when the limits are disabled
+	for _, tt := range tests {
+		if err := Walk(tt.path, tt.file, Limits{}, func(Entry) error { return nil }); err != nil {
+			t.Errorf("Walk(%s) without limits error = %v", tt.file, err)
+		}
+	}
+}
+
+func TestWalkCorrupt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "broken.zip")
+	if err := os.WriteFile(path, []byte("not a zip"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := Walk(path, "broken.zip", DefaultLimits, func(Entry) error { return nil }); err == nil {
+		t.Error("Walk() expected error for a corrupt archive")
+	}
+}
+
+func zipBytes(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, name := range sortedKeys(files) {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatalf("zip Create() error = %v", err)
+		}
+		w.Write([]byte(files[name]))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("zip Close() error = %v", err)
+	}
+	return buf.Bytes()
+}
+
+func tarGz(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gzw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gzw)
+	for _, name := range sortedKeys(files) {
+		tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(files[name])), Typeflag: tar.TypeReg})
+		tw.Write([]byte(files[name]))
+	}
+	tw.Close()
+	gzw.Close()
+	return buf.Bytes()
+}
+
+func writeZip(t *testing.T, files map[string]string) string {
+	return writeTemp(t, "test.zip", zipBytes(t, files))
+}
+
+func writeTarGz(t *testing.T, files map[string]string) string {
+	return writeTemp(t, "test.tar.gz", tarGz(t, files))
+}
+
+func writeTemp(t *testing.T, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+	return path
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
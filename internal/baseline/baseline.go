@@ -0,0 +1,104 @@
+package baseline
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/babyfaceeasy/repo-scanner/internal/model"
+)
+
+// DefaultPath is the baseline file used when none is given
+const DefaultPath = "repo-scanner-baseline.json"
+
+// Load reads a baseline previously written by Write
+func Load(path string) (*model.Output, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading baseline: %w", err)
+	}
+
+	var base model.Output
+	if err := json.Unmarshal(data, &base); err != nil {
+		return nil, fmt.Errorf("parsing baseline JSON: %w", err)
+	}
+	return &base, nil
+}
+
+// Write persists the scan result as a baseline file
+func Write(path string, result *model.Output) error {
+	data, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding baseline JSON: %w", err)
+	}
+
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("writing baseline: %w", err)
+	}
+	return nil
+}
+
+// Diff compares the current result against a baseline and keeps only files that are
+// new, grew or shrank. Other sections of the current result are kept as they are.
+// Files whose path changed but whose content hash, or Git blob SHA for tree mode scans,
+// is present in the baseline are treated as moved and are not reported, unless a baseline
+// file with that hash is still at its old path, in which case the new path is a copy.
+func Diff(base, current *model.Output) *model.Output {
+	byName := make(map[string]model.FileInfo, len(base.Files))
+	byHash := make(map[string][]string, len(base.Files))
+	byBlob := make(map[string][]string, len(base.Files))
+	for _, f := range base.Files {
+		byName[f.Name] = f
+		if f.Hash != "" {
+			byHash[f.Hash] = append(byHash[f.Hash], f.Name)
+		}
+		if f.BlobSHA != "" {
+			byBlob[f.BlobSHA] = append(byBlob[f.BlobSHA], f.Name)
+		}
+	}
+
+	present := make(map[string]bool, len(current.Files))
+	for _, f := range current.Files {
+		present[f.Name] = true
+	}
+	// moved reports whether every baseline file at paths is gone from the current result
+	moved := func(paths []string) bool {
+		for _, p := range paths {
+			if present[p] {
+				return false
+			}
+		}
+		return len(paths) > 0
+	}
+
+	files := []model.FileInfo{}
+	regressions := 0
+	for _, f := range current.Files {
+		old, ok := byName[f.Name]
+		switch {
+		case !ok && f.Hash != "" && moved(byHash[f.Hash]), !ok && f.BlobSHA != "" && moved(byBlob[f.BlobSHA]):
+			continue
+		case !ok:
+			f.Status = model.StatusNew
+		case f.Size > old.Size:
+			f.Status = model.StatusGrew
+			f.BaselineSize = old.Size
+		case f.Size < old.Size:
+			f.Status = model.StatusShrank
+			f.BaselineSize = old.Size
+		default:
+			continue
+		}
+
+		if f.IsRegression() {
+			regressions++
+		}
+		files = append(files, f)
+	}
+
+	diff := *current
+	diff.Total = len(files)
+	diff.Files = files
+	diff.Regressions = regressions
+	return &diff
+}
@@ -0,0 +1,86 @@
+package baseline
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/babyfaceeasy/repo-scanner/internal/model"
+)
+
+func TestWriteLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "baseline.json")
+	want := &model.Output{
+		Total: 1,
+		Files: []model.FileInfo{
+			{Name: "large.bin", Size: 2000, Hash: "abc"},
+		},
+	}
+
+	if err := Write(path, want); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	got, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if got.Total != 1 || len(got.Files) != 1 || got.Files[0] != want.Files[0] {
+		t.Errorf("Load() = %v, want %v", got, want)
+	}
+}
+
+func TestLoadMissingFile(t *testing.T) {
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Fatal("Load() expected error for missing file, got nil")
+	}
+}
+
+func TestDiff(t *testing.T) {
+	base := &model.Output{
+		Files: []model.FileInfo{
+			{Name: "same.bin", Size: 1000, Hash: "h1"},
+			{Name: "grown.bin", Size: 1000, Hash: "h2"},
+			{Name: "shrunk.bin", Size: 3000, Hash: "h3"},
+			{Name: "old/moved.bin", Size: 5000, Hash: "h4"},
+			{Name: "removed.bin", Size: 5000, Hash: "h5"},
+			{Name: "old/tree.bin", Size: 5000, BlobSHA: "b1"},
+			{Name: "a.bin", Size: 5000, Hash: "h7"},
+			{Name: "b.bin", Size: 5000, BlobSHA: "b2"},
+		},
+	}
+	current := &model.Output{
+		Files: []model.FileInfo{
+			{Name: "same.bin", Size: 1000, Hash: "h1"},
+			{Name: "grown.bin", Size: 1500, Hash: "h2b"},
+			{Name: "shrunk.bin", Size: 2000, Hash: "h3b"},
+			{Name: "new/moved.bin", Size: 5000, Hash: "h4"},
+			{Name: "brand-new.bin", Size: 4000, Hash: "h6"},
+			{Name: "new/tree.bin", Size: 5000, BlobSHA: "b1"},
+			{Name: "a.bin", Size: 5000, Hash: "h7"},
+			{Name: "copy/a.bin", Size: 5000, Hash: "h7"}, // a copy, as a.bin is still there
+			{Name: "b.bin", Size: 5000, BlobSHA: "b2"},
+			{Name: "copy/b.bin", Size: 5000, BlobSHA: "b2"},
+		},
+	}
+
+	got := Diff(base, current)
+
+	want := map[string]model.FileInfo{
+		"grown.bin":     {Name: "grown.bin", Size: 1500, Hash: "h2b", Status: model.StatusGrew, BaselineSize: 1000},
+		"shrunk.bin":    {Name: "shrunk.bin", Size: 2000, Hash: "h3b", Status: model.StatusShrank, BaselineSize: 3000},
+		"brand-new.bin": {Name: "brand-new.bin", Size: 4000, Hash: "h6", Status: model.StatusNew},
+		"copy/a.bin":    {Name: "copy/a.bin", Size: 5000, Hash: "h7", Status: model.StatusNew},
+		"copy/b.bin":    {Name: "copy/b.bin", Size: 5000, BlobSHA: "b2", Status: model.StatusNew},
+	}
+	if got.Total != len(want) || len(got.Files) != len(want) {
+		t.Fatalf("Diff() files = %v, want %d files", got.Files, len(want))
+	}
+	for _, f := range got.Files {
+		if f != want[f.Name] {
+			t.Errorf("Diff() file %s = %+v, want %+v", f.Name, f, want[f.Name])
+		}
+	}
+	if got.Regressions != 4 {
+		t.Errorf("Diff() regressions = %d, want 4", got.Regressions)
+	}
+}
@@ -0,0 +1,418 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/babyfaceeasy/repo-scanner/pkg/logger"
+)
+
+// DefaultMaxSize is the total size of cached tarballs above which the least recently used are evicted
+const DefaultMaxSize = 2 << 30
+
+const (
+	tarballDir = "tarballs"
+	refsFile   = "refs.json"
+	lockFile   = ".lock"
+)
+
+// Entry describes a cached tarball. The cache is keyed by repository and commit,
+// so a tarball never changes once it is stored.
+type Entry struct {
+	Repo     string    `json:"repo"`
+	Commit   string    `json:"commit"`
+	Size     int64     `json:"size"`
+	Created  time.Time `json:"created"`
+	LastUsed time.Time `json:"last_used"`
+}
+
+// Ref is the commit a branch or tag last resolved to, with the ETag to revalidate it
+type Ref struct {
+	Commit string `json:"commit"`
+	ETag   string `json:"etag"`
+}
+
+// Cache stores downloaded tarballs on disk. It is safe to use from several processes at
+// once: changes to the index are made under a lock file and tarballs are moved into
+// place with a rename, so readers never see a partial file.
+type Cache struct {
+	dir     string
+	maxSize int64
+	logger  logger.Logger
+	now     func() time.Time
+}
+
+// DefaultDir returns the cache directory used when none is configured
+func DefaultDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("finding user cache directory: %w", err)
+	}
+	return filepath.Join(dir, "repo-scanner"), nil
+}
+
+// New creates a Cache in dir, evicting tarballs once they take more than maxSize bytes.
+// A maxSize of 0 disables eviction.
+func New(dir string, maxSize int64, logger logger.Logger) (*Cache, error) {
+	if maxSize < 0 {
+		return nil, fmt.Errorf("cache size must not be negative")
+	}
+	if err := os.MkdirAll(filepath.Join(dir, tarballDir), 0o755); err != nil {
+		return nil, fmt.Errorf("creating cache directory: %w", err)
+	}
+	return &Cache{dir: dir, maxSize: maxSize, logger: logger, now: time.Now}, nil
+}
+
+// Dir returns the directory the cache lives in
+func (c *Cache) Dir() string {
+	return c.dir
+}
+
+// key returns the file name prefix of the tarball for repo at commit
+func key(repo, commit string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(repo) + "@" + commit))
+	return hex.EncodeToString(sum[:])
+}
+
+func (c *Cache) tarballPath(k string) string {
+	return filepath.Join(c.dir, tarballDir, k+".tar.gz")
+}
+
+func (c *Cache) entryPath(k string) string {
+	return filepath.Join(c.dir, tarballDir, k+".json")
+}
+
+// Open returns the cached tarball of repo at commit, or false when it is not cached
+func (c *Cache) Open(repo, commit string) (*os.File, bool, error) {
+	var f *os.File
+	err := c.locked(func() error {
+		k := key(repo, commit)
+		entry, err := c.readEntry(k)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		f, err = os.Open(c.tarballPath(k))
+		if errors.Is(err, os.ErrNotExist) {
+			// the tarball went missing, so the entry is dropped and the repo downloaded again
+			os.Remove(c.entryPath(k))
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("opening cached tarball: %w", err)
+		}
+
+		entry.LastUsed = c.now()
+		return c.writeEntry(k, entry)
+	})
+	if err != nil {
+		if f != nil {
+			f.Close()
+		}
+		return nil, false, err
+	}
+	return f, f != nil, nil
+}
+
+// Put moves the tarball at srcPath into the cache as repo at commit and evicts the least
+// recently used tarballs if the cache has grown past its maximum size
+func (c *Cache) Put(repo, commit, srcPath string) error {
+	k := key(repo, commit)
+
+	// the tarball is staged next to its final name so the rename below is atomic
+	tmp, err := os.CreateTemp(filepath.Join(c.dir, tarballDir), k+"-*.tmp")
+	if err != nil {
+		return fmt.Errorf("creating cache file: %w", err)
+	}
+	tmpPath := tmp.Name()
+	tmp.Close()
+	defer os.Remove(tmpPath)
+
+	if err := os.Rename(srcPath, tmpPath); err != nil {
+		// srcPath may be on another file system
+		if err := copyFile(srcPath, tmpPath); err != nil {
+			return err
+		}
+	}
+	info, err := os.Stat(tmpPath)
+	if err != nil {
+		return fmt.Errorf("checking cache file: %w", err)
+	}
+
+	return c.locked(func() error {
+		if err := os.Rename(tmpPath, c.tarballPath(k)); err != nil {
+			return fmt.Errorf("storing cached tarball: %w", err)
+		}
+		now := c.now()
+		entry := Entry{Repo: repo, Commit: commit, Size: info.Size(), Created: now, LastUsed: now}
+		if err := c.writeEntry(k, entry); err != nil {
+			return err
+		}
+		c.logger.Info("Cached tarball", "repo", repo, "commit", commit, "bytes", info.Size())
+
+		if c.maxSize == 0 {
+			return nil
+		}
+		_, err := c.evict(c.maxSize, k)
+		return err
+	})
+}
+
+// Remove drops the cached tarball of repo at commit, e.g. when it turned out to be corrupt
+func (c *Cache) Remove(repo, commit string) error {
+	return c.locked(func() error {
+		k := key(repo, commit)
+		if err := os.Remove(c.tarballPath(k)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("removing cached tarball: %w", err)
+		}
+		if err := os.Remove(c.entryPath(k)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("removing cache entry: %w", err)
+		}
+		c.logger.Info("Removed cached tarball", "repo", repo, "commit", commit)
+		return nil
+	})
+}
+
+// Ref returns what repo at ref resolved to last time, or false when it was never resolved
+func (c *Cache) Ref(repo, ref string) (Ref, bool, error) {
+	var r Ref
+	var ok bool
+	err := c.locked(func() error {
+		refs, err := c.readRefs()
+		if err != nil {
+			return err
+		}
+		r, ok = refs[refKey(repo, ref)]
+		return nil
+	})
+	return r, ok, err
+}
+
+// SetRef records the commit repo at ref resolved to and the ETag of that response
+func (c *Cache) SetRef(repo, ref string, r Ref) error {
+	return c.locked(func() error {
+		refs, err := c.readRefs()
+		if err != nil {
+			return err
+		}
+		refs[refKey(repo, ref)] = r
+		return c.writeJSON(filepath.Join(c.dir, refsFile), refs)
+	})
+}
+
+func refKey(repo, ref string) string {
+	return strings.ToLower(repo) + "@" + ref
+}
+
+// List returns the cached tarballs, most recently used first
+func (c *Cache) List() ([]Entry, error) {
+	entries := []Entry{}
+	err := c.locked(func() error {
+		byKey, err := c.entries()
+		if err != nil {
+			return err
+		}
+		for _, e := range byKey {
+			entries = append(entries, e)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sortByLastUsed(entries)
+	return entries, nil
+}
+
+// Prune evicts the least recently used tarballs until the rest take at most maxSize bytes
+// and returns the evicted entries
+func (c *Cache) Prune(maxSize int64) ([]Entry, error) {
+	var evicted []Entry
+	err := c.locked(func() error {
+		var err error
+		evicted, err = c.evict(maxSize, "")
+		return err
+	})
+	return evicted, err
+}
+
+// Clear removes every cached tarball and resolved ref
+func (c *Cache) Clear() error {
+	return c.locked(func() error {
+		if err := os.RemoveAll(filepath.Join(c.dir, tarballDir)); err != nil {
+			return fmt.Errorf("clearing cache: %w", err)
+		}
+		if err := os.Remove(filepath.Join(c.dir, refsFile)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("clearing cache: %w", err)
+		}
+		if err := os.MkdirAll(filepath.Join(c.dir, tarballDir), 0o755); err != nil {
+			return fmt.Errorf("creating cache directory: %w", err)
+		}
+		return nil
+	})
+}
+
+// evict removes the least recently used tarballs, except keep, until the total is at most maxSize.
+// It must be called with the lock held.
+func (c *Cache) evict(maxSize int64, keep string) ([]Entry, error) {
+	byKey, err := c.entries()
+	if err != nil {
+		return nil, err
+	}
+
+	var total int64
+	keys := make([]string, 0, len(byKey))
+	for k, e := range byKey {
+		total += e.Size
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return byKey[keys[i]].LastUsed.Before(byKey[keys[j]].LastUsed)
+	})
+
+	evicted := []Entry{}
+	for _, k := range keys {
+		if total <= maxSize {
+			break
+		}
+		if k == keep {
+			continue
+		}
+		if err := os.Remove(c.tarballPath(k)); err != nil && !os.IsNotExist(err) {
+			// e.g. a tarball still open by another process on Windows
+			c.logger.Warn("Failed to evict cached tarball", "repo", byKey[k].Repo, "commit", byKey[k].Commit, "error", err)
+			continue
+		}
+		os.Remove(c.entryPath(k))
+		total -= byKey[k].Size
+		evicted = append(evicted, byKey[k])
+		c.logger.Info("Evicted cached tarball", "repo", byKey[k].Repo, "commit", byKey[k].Commit, "bytes", byKey[k].Size)
+	}
+	return evicted, nil
+}
+
+// entries reads the index of every cached tarball by key
+func (c *Cache) entries() (map[string]Entry, error) {
+	files, err := os.ReadDir(filepath.Join(c.dir, tarballDir))
+	if err != nil {
+		return nil, fmt.Errorf("reading cache directory: %w", err)
+	}
+
+	byKey := make(map[string]Entry)
+	for _, f := range files {
+		k, ok := strings.CutSuffix(f.Name(), ".json")
+		if !ok {
+			continue
+		}
+		entry, err := c.readEntry(k)
+		if err != nil {
+			c.logger.Warn("Skipping unreadable cache entry", "file", f.Name(), "error", err)
+			continue
+		}
+		byKey[k] = entry
+	}
+	return byKey, nil
+}
+
+func (c *Cache) readEntry(k string) (Entry, error) {
+	var entry Entry
+	data, err := os.ReadFile(c.entryPath(k))
+	if err != nil {
+		return entry, err
+	}
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return entry, fmt.Errorf("parsing cache entry: %w", err)
+	}
+	return entry, nil
+}
+
+func (c *Cache) writeEntry(k string, entry Entry) error {
+	return c.writeJSON(c.entryPath(k), entry)
+}
+
+func (c *Cache) readRefs() (map[string]Ref, error) {
+	refs := make(map[string]Ref)
+	data, err := os.ReadFile(filepath.Join(c.dir, refsFile))
+	if os.IsNotExist(err) {
+		return refs, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading cached refs: %w", err)
+	}
+	if err := json.Unmarshal(data, &refs); err != nil {
+		// a corrupt index only costs a revalidation, so it is started afresh
+		c.logger.Warn("Discarding unreadable cached refs", "error", err)
+		return make(map[string]Ref), nil
+	}
+	return refs, nil
+}
+
+// writeJSON replaces path with v through a rename so readers never see a partial file
+func (c *Cache) writeJSON(path string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("encoding cache index: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("writing cache index: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("writing cache index: %w", err)
+	}
+	return nil
+}
+
+// locked runs fn while holding the cache lock, which is shared with other processes
+func (c *Cache) locked(fn func() error) error {
+	f, err := os.OpenFile(filepath.Join(c.dir, lockFile), os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return fmt.Errorf("opening cache lock: %w", err)
+	}
+	defer f.Close()
+
+	if err := lock(f); err != nil {
+		return fmt.Errorf("locking cache: %w", err)
+	}
+	defer unlock(f)
+	return fn()
+}
+
+func sortByLastUsed(entries []Entry) {
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].LastUsed.After(entries[j].LastUsed)
+	})
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("opening tarball: %w", err)
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return fmt.Errorf("creating cache file: %w", err)
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return fmt.Errorf("copying tarball into cache: %w", err)
+	}
+	if err := out.Close(); err != nil {
+		return fmt.Errorf("copying tarball into cache: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,207 @@
+package cache
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type mockLogger struct {
+	mu   sync.Mutex
+	logs []string
+}
+
+func (m *mockLogger) log(msg string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.logs = append(m.logs, msg)
+}
+
+func (m *mockLogger) Info(msg string, fields ...interface{})  { m.log(msg) }
+func (m *mockLogger) Error(msg string, fields ...interface{}) { m.log(msg) }
+func (m *mockLogger) Warn(msg string, fields ...interface{})  { m.log(msg) }
+func (m *mockLogger) Debug(msg string, fields ...interface{}) { m.log(msg) }
+func (m *mockLogger) Fatal(msg string, fields ...interface{}) { m.log(msg) }
+
+// writeTarball creates a stand-in tarball of size bytes to put in the cache
+func writeTarball(t *testing.T, size int) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "spool.tar.gz")
+	if err := os.WriteFile(path, []byte(strings.Repeat("x", size)), 0o644); err != nil {
+		t.Fatalf("writing tarball: %v", err)
+	}
+	return path
+}
+
+func TestPutAndOpen(t *testing.T) {
+	c, err := New(t.TempDir(), 0, &mockLogger{})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	if _, ok, err := c.Open("owner/repo", "abc123"); ok || err != nil {
+		t.Fatalf("Open() on an empty cache = %v, %v, want a miss", ok, err)
+	}
+
+	src := writeTarball(t, 100)
+	if err := c.Put("owner/repo", "abc123", src); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	if _, err := os.Stat(src); !os.IsNotExist(err) {
+		t.Errorf("Put() left the source tarball behind")
+	}
+
+	f, ok, err := c.Open("Owner/Repo", "abc123")
+	if err != nil || !ok {
+		t.Fatalf("Open() = %v, %v, want a hit regardless of case", ok, err)
+	}
+	data, _ := io.ReadAll(f)
+	f.Close()
+	if len(data) != 100 {
+		t.Errorf("cached tarball has %d bytes, want 100", len(data))
+	}
+
+	if _, ok, _ := c.Open("owner/repo", "def456"); ok {
+		t.Error("Open() hit for a commit that was never cached")
+	}
+
+	// a tarball removed behind the cache's back is a miss, not an error
+	os.Remove(c.tarballPath(key("owner/repo", "abc123")))
+	if _, ok, err := c.Open("owner/repo", "abc123"); ok || err != nil {
+		t.Errorf("Open() of a missing tarball = %v, %v, want a miss", ok, err)
+	}
+
+	if err := c.Put("owner/repo", "abc123", writeTarball(t, 100)); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	if err := c.Remove("owner/repo", "abc123"); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+	if _, ok, err := c.Open("owner/repo", "abc123"); ok || err != nil {
+		t.Errorf("Open() after Remove() = %v, %v, want a miss", ok, err)
+	}
+	if entries, _ := c.List(); len(entries) != 0 {
+		t.Errorf("List() after Remove() = %+v, want empty", entries)
+	}
+}
+
+func TestEviction(t *testing.T) {
+	c, err := New(t.TempDir(), 250, &mockLogger{})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	now := time.Unix(1700000000, 0)
+	c.now = func() time.Time { now = now.Add(time.Minute); return now }
+
+	c.Put("owner/a", "1", writeTarball(t, 100))
+	c.Put("owner/b", "1", writeTarball(t, 100))
+
+	// using a makes b the least recently used
+	f, _, _ := c.Open("owner/a", "1")
+	f.Close()
+
+	c.Put("owner/c", "1", writeTarball(t, 100))
+
+	entries, err := c.List()
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	var got []string
+	for _, e := range entries {
+		got = append(got, e.Repo)
+	}
+	if strings.Join(got, ",") != "owner/c,owner/a" {
+		t.Errorf("List() = %v, want owner/c,owner/a", got)
+	}
+
+	evicted, err := c.Prune(100)
+	if err != nil {
+		t.Fatalf("Prune() error = %v", err)
+	}
+	if len(evicted) != 1 || evicted[0].Repo != "owner/a" {
+		t.Errorf("Prune() evicted %v, want owner/a", evicted)
+	}
+
+	if err := c.Clear(); err != nil {
+		t.Fatalf("Clear() error = %v", err)
+	}
+	if entries, _ := c.List(); len(entries) != 0 {
+		t.Errorf("List() after Clear() = %v, want none", entries)
+	}
+}
+
+func TestRefs(t *testing.T) {
+	dir := t.TempDir()
+	c, err := New(dir, 0, &mockLogger{})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	if _, ok, err := c.Ref("owner/repo", "main"); ok || err != nil {
+		t.Fatalf("Ref() = %v, %v, want unknown", ok, err)
+	}
+	if err := c.SetRef("owner/repo", "main", Ref{Commit: "abc123", ETag: `"e1"`}); err != nil {
+		t.Fatalf("SetRef() error = %v", err)
+	}
+
+	// another process sharing the directory sees the same refs
+	other, _ := New(dir, 0, &mockLogger{})
+	r, ok, err := other.Ref("owner/repo", "main")
+	if err != nil || !ok || r.Commit != "abc123" || r.ETag != `"e1"` {
+		t.Errorf("Ref() = %+v, %v, %v, want abc123 with its ETag", r, ok, err)
+	}
+}
+
+func TestConcurrentAccess(t *testing.T) {
+	dir := t.TempDir()
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			// separate Cache values stand in for separate processes
+			c, err := New(dir, 1000, &mockLogger{})
+			if err != nil {
+				errs <- err
+				return
+			}
+			src := filepath.Join(t.TempDir(), "spool.tar.gz")
+			os.WriteFile(src, []byte(strings.Repeat("x", 100)), 0o644)
+			if err := c.Put(fmt.Sprintf("owner/repo%d", i%5), "1", src); err != nil {
+				errs <- err
+				return
+			}
+			if err := c.SetRef(fmt.Sprintf("owner/repo%d", i), "main", Ref{Commit: "1"}); err != nil {
+				errs <- err
+				return
+			}
+			if f, ok, err := c.Open(fmt.Sprintf("owner/repo%d", i%5), "1"); err != nil {
+				errs <- err
+			} else if ok {
+				f.Close()
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("concurrent access error = %v", err)
+	}
+
+	c, _ := New(dir, 0, &mockLogger{})
+	entries, err := c.List()
+	if err != nil || len(entries) != 5 {
+		t.Errorf("List() = %d entries, %v, want 5", len(entries), err)
+	}
+	for i := 0; i < 20; i++ {
+		if _, ok, _ := c.Ref(fmt.Sprintf("owner/repo%d", i), "main"); !ok {
+			t.Errorf("ref of owner/repo%d lost to a concurrent write", i)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+//go:build !unix
+
+package cache
+
+import (
+	"errors"
+	"os"
+	"time"
+)
+
+// lockTimeout is how long a lock marker may exist before it is assumed to be left by a crashed process
+const lockTimeout = time.Minute
+
+// lock marks f as held by creating a sibling file exclusively, waiting while another process holds it
+func lock(f *os.File) error {
+	marker := f.Name() + ".held"
+	for {
+		m, err := os.OpenFile(marker, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+		if err == nil {
+			return m.Close()
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return err
+		}
+		if info, err := os.Stat(marker); err == nil && time.Since(info.ModTime()) > lockTimeout {
+			os.Remove(marker)
+			continue
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func unlock(f *os.File) error {
+	return os.Remove(f.Name() + ".held")
+}
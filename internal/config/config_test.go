@@ -36,6 +36,63 @@ func TestParse(t *testing.T) {
 			input:   `{"clone_url":"https://github.com/owner/repo.git","size":-1.0}`,
 			wantErr: true,
 		},
+		{
+			name:  "size omitted in top_n mode",
+			input: `{"clone_url":"https://github.com/owner/repo.git","top_n":50}`,
+			expected: &model.Config{
+				CloneURL: "https://github.com/owner/repo.git",
+			},
+		},
+		{
+			name:    "size omitted without top_n or histogram",
+			input:   `{"clone_url":"https://github.com/owner/repo.git"}`,
+			wantErr: true,
+		},
+		{
+			name:    "invalid rule pattern",
+			input:   `{"clone_url":"https://github.com/owner/repo.git","size":1.0,"rules":[{"pattern":"[a-","size":2}]}`,
+			wantErr: true,
+		},
+		{
+			name:    "unknown rule precedence",
+			input:   `{"clone_url":"https://github.com/owner/repo.git","size":1.0,"rule_precedence":"last"}`,
+			wantErr: true,
+		},
+		{
+			name:    "unknown category",
+			input:   `{"clone_url":"https://github.com/owner/repo.git","size":1.0,"categories":["pictures"]}`,
+			wantErr: true,
+		},
+		{
+			name:    "negative policy max_count",
+			input:   `{"clone_url":"https://github.com/owner/repo.git","size":1.0,"policy":{"max_count":-1}}`,
+			wantErr: true,
+		},
+		{
+			name:  "tree mode",
+			input: `{"clone_url":"https://github.com/owner/repo.git","size":1.0,"mode":"tree","rules":[{"pattern":"*.psd","size":50}]}`,
+			expected: &model.Config{
+				CloneURL: "https://github.com/owner/repo.git",
+				Mode:     model.ModeTree,
+				Size:     1.0,
+				Rules:    []model.SizeRule{{Pattern: "*.psd", Size: 50}},
+			},
+		},
+		{
+			name:    "unknown mode",
+			input:   `{"clone_url":"https://github.com/owner/repo.git","size":1.0,"mode":"clone"}`,
+			wantErr: true,
+		},
+		{
+			name:    "tree mode with a content option",
+			input:   `{"clone_url":"https://github.com/owner/repo.git","size":1.0,"mode":"tree","duplicates":true}`,
+			wantErr: true,
+		},
+		{
+			name:    "negative max_repo_size",
+			input:   `{"clone_url":"https://github.com/owner/repo.git","size":1.0,"max_repo_size":-5}`,
+			wantErr: true,
+		},
 	}
 
 	parser := New()
@@ -3,18 +3,55 @@ package env
 import (
 	"fmt"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
+// defaultRateLimitThreshold is used when GITHUB_RATE_LIMIT_THRESHOLD is unset
+const defaultRateLimitThreshold = 10
+
+// defaultCacheMaxSizeMB is used when CACHE_MAX_SIZE_MB is unset
+const defaultCacheMaxSizeMB = 2048
+
 // Config holds environment variables
 type Config struct {
-	GitHubToken string
-	LogEnv      string
+	GitHubToken        string
+	LogEnv             string
+	RateLimitThreshold int    // Remaining GitHub quota below which requests pause until the reset
+	CacheDir           string // Directory for cached tarballs; empty means the user cache directory
+	CacheMaxSizeMB     int    // Total size of cached tarballs before the least recently used are evicted; 0 disables eviction
+
+	// HTTP transport settings; zero timeouts leave the client defaults in place
+	ConnectTimeout        time.Duration
+	TLSHandshakeTimeout   time.Duration
+	ResponseHeaderTimeout time.Duration
+	IdleConnTimeout       time.Duration
+	ReadTimeout           time.Duration
+	HTTPSProxy            string
+	HTTPProxy             string
+	NoProxy               string
+	CAFile                string // PEM bundle trusted in addition to the system certificate authorities
+	ClientCertFile        string
+	ClientKeyFile         string
 }
 
 // Load and validates environment variables
 func Load() (*Config, error) {
+	cfg, err := LoadWithoutToken()
+	if err != nil {
+		return nil, err
+	}
+	if cfg.GitHubToken == "" {
+		return nil, fmt.Errorf("GITHUB_TOKEN is required")
+	}
+	return cfg, nil
+}
+
+// LoadWithoutToken loads and validates environment variables like Load but leaves GITHUB_TOKEN
+// optional, for commands that never call GitHub
+func LoadWithoutToken() (*Config, error) {
 
 	
 	path := os.Getenv("GODOTENV_PATH")
@@ -31,14 +68,72 @@ func Load() (*Config, error) {
 		LogEnv:      os.Getenv("LOG_ENV"),
 	}
 
-	if cfg.GitHubToken == "" {
-		return nil, fmt.Errorf("GITHUB_TOKEN is required")
-	}
-
 	// set production as the default environment
 	if cfg.LogEnv == "" {
 		cfg.LogEnv = "production"
 	}
 
+	cfg.RateLimitThreshold = defaultRateLimitThreshold
+	if val := os.Getenv("GITHUB_RATE_LIMIT_THRESHOLD"); val != "" {
+		threshold, err := strconv.Atoi(val)
+		if err != nil || threshold < 0 {
+			return nil, fmt.Errorf("GITHUB_RATE_LIMIT_THRESHOLD must be a non-negative integer")
+		}
+		cfg.RateLimitThreshold = threshold
+	}
+
+	cfg.CacheDir = os.Getenv("CACHE_DIR")
+	cfg.CacheMaxSizeMB = defaultCacheMaxSizeMB
+	if val := os.Getenv("CACHE_MAX_SIZE_MB"); val != "" {
+		size, err := strconv.Atoi(val)
+		if err != nil || size < 0 {
+			return nil, fmt.Errorf("CACHE_MAX_SIZE_MB must be a non-negative integer")
+		}
+		cfg.CacheMaxSizeMB = size
+	}
+
+	timeouts := []struct {
+		name string
+		dst  *time.Duration
+	}{
+		{"HTTP_CONNECT_TIMEOUT", &cfg.ConnectTimeout},
+		{"HTTP_TLS_HANDSHAKE_TIMEOUT", &cfg.TLSHandshakeTimeout},
+		{"HTTP_RESPONSE_HEADER_TIMEOUT", &cfg.ResponseHeaderTimeout},
+		{"HTTP_IDLE_CONN_TIMEOUT", &cfg.IdleConnTimeout},
+		{"HTTP_READ_TIMEOUT", &cfg.ReadTimeout},
+	}
+	for _, t := range timeouts {
+		val := os.Getenv(t.name)
+		if val == "" {
+			continue
+		}
+		d, err := time.ParseDuration(val)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("%s must be a non-negative duration such as 30s", t.name)
+		}
+		*t.dst = d
+	}
+
+	// the lowercase forms are what curl and most tools read, so both are honoured
+	cfg.HTTPSProxy = getenvAny("HTTPS_PROXY", "https_proxy")
+	cfg.HTTPProxy = getenvAny("HTTP_PROXY", "http_proxy")
+	cfg.NoProxy = getenvAny("NO_PROXY", "no_proxy")
+	cfg.CAFile = os.Getenv("TLS_CA_FILE")
+	cfg.ClientCertFile = os.Getenv("TLS_CLIENT_CERT_FILE")
+	cfg.ClientKeyFile = os.Getenv("TLS_CLIENT_KEY_FILE")
+	if (cfg.ClientCertFile == "") != (cfg.ClientKeyFile == "") {
+		return nil, fmt.Errorf("TLS_CLIENT_CERT_FILE and TLS_CLIENT_KEY_FILE must be set together")
+	}
+
 	return cfg, nil
 }
+
+// getenvAny returns the value of the first of names that is set
+func getenvAny(names ...string) string {
+	for _, name := range names {
+		if val := os.Getenv(name); val != "" {
+			return val
+		}
+	}
+	return ""
+}
@@ -4,6 +4,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 )
 
 func TestLoad(t *testing.T) {
@@ -44,6 +45,11 @@ func TestLoadNoGitHubToken(t *testing.T) {
 	if err == nil || err.Error() != "GITHUB_TOKEN is required" {
 		t.Errorf("Load() error = %v, want GITHUB_TOKEN is required", err)
 	}
+
+	cfg, err := LoadWithoutToken()
+	if err != nil || cfg.GitHubToken != "" || cfg.LogEnv != "production" {
+		t.Errorf("LoadWithoutToken() = %+v, %v, want defaults without a token", cfg, err)
+	}
 }
 
 func TestLoadNoEnvFile(t *testing.T) {
@@ -65,4 +71,104 @@ func TestLoadNoEnvFile(t *testing.T) {
 	if cfg.LogEnv != "production" {
 		t.Errorf("LogEnv = %v, want production", cfg.LogEnv)
 	}
+	if cfg.RateLimitThreshold != 10 {
+		t.Errorf("RateLimitThreshold = %v, want 10", cfg.RateLimitThreshold)
+	}
+}
+
+func TestLoadRateLimitThreshold(t *testing.T) {
+	os.Unsetenv("GODOTENV_PATH")
+	os.Setenv("GITHUB_TOKEN", "ghp_testtoken")
+	defer os.Unsetenv("GITHUB_TOKEN")
+
+	os.Setenv("GITHUB_RATE_LIMIT_THRESHOLD", "50")
+	defer os.Unsetenv("GITHUB_RATE_LIMIT_THRESHOLD")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.RateLimitThreshold != 50 {
+		t.Errorf("RateLimitThreshold = %v, want 50", cfg.RateLimitThreshold)
+	}
+
+	os.Setenv("GITHUB_RATE_LIMIT_THRESHOLD", "lots")
+	if _, err := Load(); err == nil {
+		t.Error("Load() expected error for a non-numeric threshold")
+	}
+}
+
+func TestLoadCache(t *testing.T) {
+	os.Unsetenv("GODOTENV_PATH")
+	os.Setenv("GITHUB_TOKEN", "ghp_testtoken")
+	defer os.Unsetenv("GITHUB_TOKEN")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.CacheDir != "" || cfg.CacheMaxSizeMB != defaultCacheMaxSizeMB {
+		t.Errorf("CacheDir, CacheMaxSizeMB = %q, %v, want defaults", cfg.CacheDir, cfg.CacheMaxSizeMB)
+	}
+
+	os.Setenv("CACHE_DIR", "/var/cache/repo-scanner")
+	defer os.Unsetenv("CACHE_DIR")
+	os.Setenv("CACHE_MAX_SIZE_MB", "512")
+	defer os.Unsetenv("CACHE_MAX_SIZE_MB")
+
+	cfg, err = Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.CacheDir != "/var/cache/repo-scanner" || cfg.CacheMaxSizeMB != 512 {
+		t.Errorf("CacheDir, CacheMaxSizeMB = %q, %v, want /var/cache/repo-scanner, 512", cfg.CacheDir, cfg.CacheMaxSizeMB)
+	}
+
+	os.Setenv("CACHE_MAX_SIZE_MB", "-1")
+	if _, err := Load(); err == nil {
+		t.Error("Load() expected error for a negative cache size")
+	}
+}
+
+func TestLoadTransport(t *testing.T) {
+	os.Unsetenv("GODOTENV_PATH")
+	vars := map[string]string{
+		"GITHUB_TOKEN":                 "ghp_testtoken",
+		"HTTP_CONNECT_TIMEOUT":         "5s",
+		"HTTP_RESPONSE_HEADER_TIMEOUT": "1m",
+		"https_proxy":                  "http://proxy.corp:3128",
+		"NO_PROXY":                     "github.corp,.internal",
+		"TLS_CA_FILE":                  "/etc/ssl/corp-ca.pem",
+		"TLS_CLIENT_CERT_FILE":         "/etc/ssl/client.pem",
+		"TLS_CLIENT_KEY_FILE":          "/etc/ssl/client-key.pem",
+	}
+	for k, v := range vars {
+		os.Setenv(k, v)
+		defer os.Unsetenv(k)
+	}
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.ConnectTimeout != 5*time.Second || cfg.ResponseHeaderTimeout != time.Minute || cfg.ReadTimeout != 0 {
+		t.Errorf("timeouts = %v, %v, %v, want 5s, 1m and unset", cfg.ConnectTimeout, cfg.ResponseHeaderTimeout, cfg.ReadTimeout)
+	}
+	if cfg.HTTPSProxy != "http://proxy.corp:3128" || cfg.NoProxy != "github.corp,.internal" {
+		t.Errorf("proxy = %q, no proxy = %q", cfg.HTTPSProxy, cfg.NoProxy)
+	}
+	if cfg.CAFile != "/etc/ssl/corp-ca.pem" || cfg.ClientCertFile != "/etc/ssl/client.pem" || cfg.ClientKeyFile != "/etc/ssl/client-key.pem" {
+		t.Errorf("TLS files = %q, %q, %q", cfg.CAFile, cfg.ClientCertFile, cfg.ClientKeyFile)
+	}
+
+	os.Setenv("HTTP_READ_TIMEOUT", "soon")
+	if _, err := Load(); err == nil {
+		t.Error("Load() expected error for an invalid duration")
+	}
+	os.Unsetenv("HTTP_READ_TIMEOUT")
+
+	os.Unsetenv("TLS_CLIENT_KEY_FILE")
+	if _, err := Load(); err == nil {
+		t.Error("Load() expected error for a client certificate without a key")
+	}
 }
@@ -0,0 +1,195 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/babyfaceeasy/repo-scanner/internal/progress"
+)
+
+// spool is a tarball download kept on disk so that a retry can resume it
+type spool struct {
+	path string
+	url  string // tarball URL the file was downloaded from
+	etag string // strong ETag of the response, required to resume safely
+}
+
+// spools tracks partial downloads by clone URL across retries of DownloadRepo
+type spools struct {
+	mu    sync.Mutex
+	byURL map[string]*spool
+}
+
+func (s *spools) get(cloneURL string) *spool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.byURL[cloneURL]
+}
+
+func (s *spools) set(cloneURL string, sp *spool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.byURL == nil {
+		s.byURL = make(map[string]*spool)
+	}
+	s.byURL[cloneURL] = sp
+}
+
+// remove forgets the download and deletes its file
+func (s *spools) remove(cloneURL string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if sp, ok := s.byURL[cloneURL]; ok {
+		os.Remove(sp.path)
+		delete(s.byURL, cloneURL)
+	}
+}
+
+// downloadTarball fetches the compressed tarball into a spool file and returns its path.
+// When an earlier attempt was interrupted and the server sent a strong ETag, the download
+// resumes from where it stopped using Range and If-Range; otherwise, or when the server
+// ignores the range, it starts over. The spool is kept after a failure the next attempt
+// can resume, and deleted after any other failure.
+func (c *Client) downloadTarball(ctx context.Context, cloneURL, tarballURL string, tracker *progress.Tracker) (_ string, err error) {
+	sp := c.spools.get(cloneURL)
+	if sp != nil && sp.url != tarballURL {
+		// the clone URL now points at another tarball, so the partial file is of no use
+		c.spools.remove(cloneURL)
+		sp = nil
+	}
+	if sp == nil {
+		f, err := os.CreateTemp(c.spoolDir, "repo-scanner-*.tar.gz.part")
+		if err != nil {
+			return "", fmt.Errorf("creating spool file: %w", err)
+		}
+		f.Close()
+		sp = &spool{path: f.Name(), url: tarballURL}
+		c.spools.set(cloneURL, sp)
+	}
+	defer func() {
+		var classified interface{ Retryable() bool }
+		if err != nil && (sp.etag == "" || !errors.As(err, &classified) || !classified.Retryable()) {
+			c.spools.remove(cloneURL)
+		}
+	}()
+
+	var offset int64
+	if info, err := os.Stat(sp.path); err == nil && sp.etag != "" {
+		offset = info.Size()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", tarballURL, nil)
+	if err != nil {
+		return "", fmt.Errorf("creating request: %w", err)
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		req.Header.Set("If-Range", sp.etag)
+		c.logger.Info("Resuming tarball download", "url", tarballURL, "offset", offset)
+	}
+
+	resp, err := c.do(req)
+	if err != nil {
+		return "", &NetworkError{Op: "fetching tarball", Err: err}
+	}
+	defer resp.Body.Close()
+
+	var total int64 = -1
+	flags := os.O_WRONLY | os.O_CREATE
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		start, size, err := parseContentRange(resp.Header.Get("Content-Range"))
+		if err != nil || start != offset {
+			// an unusable partial response is discarded rather than spliced in
+			sp.etag = ""
+			return "", &HTTPStatusError{StatusCode: resp.StatusCode, URL: tarballURL}
+		}
+		total = size
+		flags |= os.O_APPEND
+		tracker.Start(offset, total)
+	case http.StatusOK:
+		// a full response, either requested or because the range or ETag no longer applies
+		if offset > 0 {
+			c.logger.Info("Server sent the full tarball, restarting download", "url", tarballURL)
+		}
+		sp.etag = strongETag(resp.Header.Get("ETag"))
+		if resp.ContentLength >= 0 {
+			total = resp.ContentLength
+		}
+		flags |= os.O_TRUNC
+		tracker.Start(0, total)
+	case http.StatusRequestedRangeNotSatisfiable:
+		sp.etag = ""
+		return "", &HTTPStatusError{StatusCode: resp.StatusCode, URL: tarballURL}
+	default:
+		return "", c.statusError(resp)
+	}
+
+	f, err := os.OpenFile(sp.path, flags, 0o600)
+	if err != nil {
+		return "", fmt.Errorf("opening spool file: %w", err)
+	}
+	n, copyErr := io.Copy(f, tracker.Reader(resp.Body))
+	closeErr := f.Close()
+	if copyErr != nil {
+		c.logger.Warn("Tarball download interrupted", "url", tarballURL, "received", offset+n, "resumable", sp.etag != "")
+		return "", &NetworkError{Op: "reading tarball", Err: copyErr}
+	}
+	if closeErr != nil {
+		return "", fmt.Errorf("writing spool file: %w", closeErr)
+	}
+
+	info, err := os.Stat(sp.path)
+	if err != nil {
+		return "", fmt.Errorf("checking spool file: %w", err)
+	}
+	if total >= 0 && info.Size() != total {
+		return "", &NetworkError{Op: "reading tarball", Err: fmt.Errorf("received %d of %d bytes: %w", info.Size(), total, io.ErrUnexpectedEOF)}
+	}
+
+	c.logger.Info("Fetched tarball", "url", tarballURL, "bytes", info.Size())
+	return sp.path, nil
+}
+
+// strongETag returns etag if it can be used with If-Range, which rejects weak validators
+func strongETag(etag string) string {
+	if etag == "" || strings.HasPrefix(etag, "W/") {
+		return ""
+	}
+	return etag
+}
+
+// parseContentRange parses "bytes start-end/size", returning -1 for an unknown size
+func parseContentRange(val string) (int64, int64, error) {
+	rng, ok := strings.CutPrefix(val, "bytes ")
+	if !ok {
+		return 0, 0, errors.New("invalid Content-Range")
+	}
+	span, size, ok := strings.Cut(rng, "/")
+	if !ok {
+		return 0, 0, errors.New("invalid Content-Range")
+	}
+	startStr, _, ok := strings.Cut(span, "-")
+	if !ok {
+		return 0, 0, errors.New("invalid Content-Range")
+	}
+	start, err := strconv.ParseInt(startStr, 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid Content-Range: %w", err)
+	}
+	if size == "*" {
+		return start, -1, nil
+	}
+	total, err := strconv.ParseInt(size, 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid Content-Range: %w", err)
+	}
+	return start, total, nil
+}
@@ -0,0 +1,145 @@
+package github
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"syscall"
+)
+
+// Errors returned by Client implement Retryable so callers can decide whether another
+// attempt may succeed without inspecting error strings. *RetryAfterError is always retryable.
+
+// HTTPStatusError is an unsuccessful response not covered by a more specific error
+type HTTPStatusError struct {
+	StatusCode int
+	URL        string
+}
+
+func (e *HTTPStatusError) Error() string {
+	return fmt.Sprintf("unexpected status code: %d", e.StatusCode)
+}
+
+// Retryable reports whether the status is a server-side or timeout failure
+func (e *HTTPStatusError) Retryable() bool {
+	return e.StatusCode >= 500 || e.StatusCode == http.StatusRequestTimeout
+}
+
+// NotFoundError is a 404: the repository, ref or path does not exist or is private
+type NotFoundError struct {
+	URL string
+}
+
+func (e *NotFoundError) Error() string {
+	return fmt.Sprintf("not found: %s", e.URL)
+}
+
+// Retryable always returns false
+func (e *NotFoundError) Retryable() bool { return false }
+
+// UnauthorizedError is a 401 or a 403 that is not a rate limit: the token is missing,
+// invalid or lacks access to the resource
+type UnauthorizedError struct {
+	StatusCode int
+	URL        string
+}
+
+func (e *UnauthorizedError) Error() string {
+	if e.StatusCode == http.StatusForbidden {
+		return fmt.Sprintf("forbidden: token lacks access to %s", e.URL)
+	}
+	return fmt.Sprintf("unauthorized: invalid or missing token for %s", e.URL)
+}
+
+// Retryable always returns false
+func (e *UnauthorizedError) Retryable() bool { return false }
+
+// Retryable always returns true
+func (e *RetryAfterError) Retryable() bool { return true }
+
+// NetworkError is a failure to send a request or to read a response body
+type NetworkError struct {
+	Op  string
+	Err error
+}
+
+func (e *NetworkError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Op, e.Err)
+}
+
+func (e *NetworkError) Unwrap() error {
+	return e.Err
+}
+
+// Retryable reports whether the failure is transient: timeouts, refused or reset
+// connections, DNS failures and responses cut short
+func (e *NetworkError) Retryable() bool {
+	return isTransient(e.Err)
+}
+
+// ExtractionError is a failure to decompress or unpack the tarball. A stream cut short by
+// the network is retryable, while invalid archive contents or disk errors are not.
+type ExtractionError struct {
+	Path string // Entry or file being processed, if any
+	Err  error
+}
+
+func (e *ExtractionError) Error() string {
+	if e.Path == "" {
+		return fmt.Sprintf("extracting tarball: %v", e.Err)
+	}
+	return fmt.Sprintf("extracting %s: %v", e.Path, e.Err)
+}
+
+func (e *ExtractionError) Unwrap() error {
+	return e.Err
+}
+
+// Retryable reports whether the stream was interrupted rather than invalid
+func (e *ExtractionError) Retryable() bool {
+	return isTransient(e.Err)
+}
+
+// isCorrupt reports whether err is an *ExtractionError caused by the archive itself, such as
+// a bad gzip or tar header, rather than by the file system the files are extracted to
+func isCorrupt(err error) bool {
+	var extErr *ExtractionError
+	if !errors.As(err, &extErr) {
+		return false
+	}
+	var pathErr *os.PathError
+	var linkErr *os.LinkError
+	var syscallErr *os.SyscallError
+	var errno syscall.Errno
+	return !errors.As(extErr.Err, &pathErr) && !errors.As(extErr.Err, &linkErr) &&
+		!errors.As(extErr.Err, &syscallErr) && !errors.As(extErr.Err, &errno)
+}
+
+// isTransient reports whether err comes from the network rather than from the request or the data
+func isTransient(err error) bool {
+	if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return true
+	}
+
+	var errno syscall.Errno
+	if errors.As(err, &errno) {
+		switch errno {
+		case syscall.ECONNRESET, syscall.ETIMEDOUT, syscall.ECONNREFUSED, syscall.EPIPE, syscall.ECONNABORTED:
+			return true
+		}
+	}
+	return false
+}
@@ -4,22 +4,35 @@ import (
 	"archive/tar"
 	"bytes"
 	"compress/gzip"
+	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
-	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/babyfaceeasy/repo-scanner/internal/cache"
+	"github.com/babyfaceeasy/repo-scanner/internal/model"
+	"github.com/babyfaceeasy/repo-scanner/internal/progress"
 	"github.com/babyfaceeasy/repo-scanner/pkg/logger"
 )
 
-// GitHubClient defines the interface for GitHub interactions
+// apiBaseURL is the root of the GitHub REST API
+const apiBaseURL = "https://api.github.com"
+
+// GitHubClient defines the interface for GitHub interactions. The requests a method sends are
+// bound by its ctx, so callers such as retry.Retrier can cancel or time out an attempt.
 type GitHubClient interface {
-	DownloadRepo(cloneURL, destDir string) error
+	DownloadRepo(ctx context.Context, cloneURL, destDir string) error
+	SubmoduleCommit(ctx context.Context, cloneURL, path string) (string, error)
+	Repository(ctx context.Context, cloneURL string) (*model.Repository, error)
+	ScanTree(ctx context.Context, repo, ref string, threshold ThresholdFunc) (*model.Output, error)
+	DiscardDownload(cloneURL string)
 }
 
 // Client is a GitHub API client
@@ -27,17 +40,192 @@ type Client struct {
 	httpClient           *http.Client
 	token                string
 	logger               logger.Logger
+	limiter              *RateLimiter
+	spools               spools
+	spoolDir             string
+	cache                *cache.Cache
+	progress             progress.Reporter
+	apiBaseURL           string
 	cloneURLToTarballURL func(string) (string, error)
 }
 
+// Option configures a Client
+type Option func(*Client)
+
+// WithRateLimiter makes the client share limiter, e.g. with other clients using the same token
+func WithRateLimiter(limiter *RateLimiter) Option {
+	return func(c *Client) {
+		c.limiter = limiter
+	}
+}
+
+// WithCache makes the client keep downloaded tarballs in cache and reuse them for unchanged commits
+func WithCache(cache *cache.Cache) Option {
+	return func(c *Client) {
+		c.cache = cache
+	}
+}
+
+// WithProgress makes the client report the progress of each download and extraction to r
+func WithProgress(r progress.Reporter) Option {
+	return func(c *Client) {
+		c.progress = r
+	}
+}
+
 // NewClient creates a new GitHub client
-func NewClient(token string, logger logger.Logger) *Client {
-	return &Client{
-		httpClient:           &http.Client{},
+func NewClient(token string, logger logger.Logger, opts ...Option) *Client {
+	// the default config has no files to load, so building its transport cannot fail
+	transport, _ := NewTransport(TransportConfig{})
+	transport.Proxy = http.ProxyFromEnvironment
+	c := &Client{
+		httpClient:           &http.Client{Transport: transport},
 		token:                token,
 		logger:               logger,
+		spoolDir:             os.TempDir(),
+		apiBaseURL:           apiBaseURL,
 		cloneURLToTarballURL: cloneURLToTarballURL,
 	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.limiter == nil {
+		c.limiter = NewRateLimiter(DefaultRateLimitThreshold, logger)
+	}
+	return c
+}
+
+// do sends req with the token, waiting while the rate limit is low and recording the quota left
+func (c *Client) do(req *http.Request) (*http.Response, error) {
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	if err := c.limiter.WaitContext(req.Context()); err != nil {
+		return nil, err
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	c.limiter.Update(resp)
+	return resp, nil
+}
+
+// SubmoduleCommit returns the commit the submodule at path is pinned to, at the ref of cloneURL
+func (c *Client) SubmoduleCommit(ctx context.Context, cloneURL, path string) (string, error) {
+	repo, ref, err := ParseCloneURL(cloneURL)
+	if err != nil {
+		return "", fmt.Errorf("converting clone URL: %w", err)
+	}
+
+	contentsURL := fmt.Sprintf("%s/repos/%s/contents/%s", c.apiBaseURL, repo, escapePath(path))
+	if ref != "" {
+		contentsURL += "?ref=" + url.QueryEscape(ref)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", contentsURL, nil)
+	if err != nil {
+		return "", fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+
+	resp, err := c.do(req)
+	if err != nil {
+		return "", &NetworkError{Op: "fetching submodule " + path, Err: err}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", c.statusError(resp)
+	}
+
+	var content struct {
+		Type string `json:"type"`
+		SHA  string `json:"sha"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&content); err != nil {
+		return "", &NetworkError{Op: "decoding contents of " + path, Err: err}
+	}
+	if content.Type != "submodule" {
+		return "", fmt.Errorf("%s is not a submodule", path)
+	}
+
+	c.logger.Debug("Resolved submodule commit", "path", path, "commit", content.SHA)
+	return content.SHA, nil
+}
+
+// Repository looks up the metadata of the repository cloneURL points at. A repository that does not
+// exist and a private one the token cannot see both give a *NotFoundError, as GitHub does not tell them apart.
+func (c *Client) Repository(ctx context.Context, cloneURL string) (*model.Repository, error) {
+	repo, _, err := ParseCloneURL(cloneURL)
+	if err != nil {
+		return nil, fmt.Errorf("converting clone URL: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%s/repos/%s", c.apiBaseURL, repo), nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, &NetworkError{Op: "fetching repository " + repo, Err: err}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, c.statusError(resp)
+	}
+
+	var meta struct {
+		FullName      string          `json:"full_name"`
+		DefaultBranch string          `json:"default_branch"`
+		Size          int64           `json:"size"`
+		Visibility    string          `json:"visibility"`
+		Private       bool            `json:"private"`
+		Archived      bool            `json:"archived"`
+		Fork          bool            `json:"fork"`
+		Permissions   map[string]bool `json:"permissions"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&meta); err != nil {
+		return nil, &NetworkError{Op: "decoding repository " + repo, Err: err}
+	}
+
+	info := &model.Repository{
+		FullName:      meta.FullName,
+		DefaultBranch: meta.DefaultBranch,
+		SizeKB:        meta.Size,
+		Visibility:    meta.Visibility,
+		Archived:      meta.Archived,
+		Fork:          meta.Fork,
+	}
+	// older GitHub Enterprise versions only report private
+	if info.Visibility == "" {
+		info.Visibility = "public"
+		if meta.Private {
+			info.Visibility = "private"
+		}
+	}
+	for _, perm := range []string{"admin", "maintain", "push", "triage", "pull"} {
+		if meta.Permissions[perm] {
+			info.Access = perm
+			break
+		}
+	}
+
+	c.logger.Info("Fetched repository metadata", "repo", info.FullName, "default_branch", info.DefaultBranch, "size_kb", info.SizeKB, "visibility", info.Visibility, "archived", info.Archived)
+	return info, nil
+}
+
+// escapePath escapes each segment of a slash separated path for use in a URL
+func escapePath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+	return strings.Join(segments, "/")
 }
 
 // DownloadRepoSequentially downloads the repository tarball and extracts it to destDir
@@ -52,33 +240,15 @@ func (c *Client) DownloadRepoSequentially(cloneURL, destDir string) error {
 	if err != nil {
 		return fmt.Errorf("creating request: %w", err)
 	}
-	if c.token != "" {
-		req.Header.Set("Authorization", "Bearer "+c.token)
-	}
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
-		return fmt.Errorf("fetching tarball: %w", err)
+		return &NetworkError{Op: "fetching tarball", Err: err}
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-
-		// handle rate-limit reached
-		if resp.StatusCode == http.StatusTooManyRequests {
-			retryAfter := 5 * time.Second // default fallback
-			if val := resp.Header.Get("Retry-After"); val != "" {
-				if secs, err := strconv.Atoi(val); err == nil {
-					retryAfter = time.Duration(secs) * time.Second
-				}
-			}
-			return &RetryAfterError{
-				Err:        fmt.Errorf("rate limited: 429 Too Many Requests"),
-				RetryAfter: retryAfter,
-			}
-		}
-
-		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		return c.statusError(resp)
 	}
 	c.logger.Info("Fetched tarball", "url", tarballURL)
 
@@ -88,7 +258,7 @@ func (c *Client) DownloadRepoSequentially(cloneURL, destDir string) error {
 
 	gzr, err := gzip.NewReader(resp.Body)
 	if err != nil {
-		return fmt.Errorf("creating gzip reader: %w", err)
+		return &ExtractionError{Err: fmt.Errorf("creating gzip reader: %w", err)}
 	}
 	defer gzr.Close()
 
@@ -99,7 +269,7 @@ func (c *Client) DownloadRepoSequentially(cloneURL, destDir string) error {
 			break
 		}
 		if err != nil {
-			return fmt.Errorf("reading tarball: %w", err)
+			return &ExtractionError{Err: fmt.Errorf("reading tarball: %w", err)}
 		}
 
 		parts := strings.SplitN(header.Name, "/", 2)
@@ -114,17 +284,17 @@ func (c *Client) DownloadRepoSequentially(cloneURL, destDir string) error {
 		switch header.Typeflag {
 		case tar.TypeDir:
 			if err := os.MkdirAll(targetPath, 0o755); err != nil {
-				return fmt.Errorf("creating directory %s: %w", targetPath, err)
+				return &ExtractionError{Path: targetPath, Err: fmt.Errorf("creating directory: %w", err)}
 			}
 			c.logger.Debug("Created directory", "path", targetPath)
 		case tar.TypeReg:
 			outFile, err := os.Create(targetPath)
 			if err != nil {
-				return fmt.Errorf("creating file %s: %w", targetPath, err)
+				return &ExtractionError{Path: targetPath, Err: fmt.Errorf("creating file: %w", err)}
 			}
 			if _, err := io.Copy(outFile, tr); err != nil {
 				outFile.Close()
-				return fmt.Errorf("writing file %s: %w", targetPath, err)
+				return &ExtractionError{Path: targetPath, Err: fmt.Errorf("writing file: %w", err)}
 			}
 			outFile.Close()
 			c.logger.Debug("Extracted file", "path", targetPath)
@@ -135,90 +305,211 @@ func (c *Client) DownloadRepoSequentially(cloneURL, destDir string) error {
 	return nil
 }
 
-// DownloadRepo downloads the repository tarball and extracts it to destDir. does it using worker pattern
-func (c *Client) DownloadRepo(cloneURL, destDir string) error {
-	tarball, err := c.getTarballStream(cloneURL)
+// DiscardDownload deletes the partial download of cloneURL kept for DownloadRepo to resume.
+// Callers that stop retrying a download call it so that the spool file does not outlive them.
+func (c *Client) DiscardDownload(cloneURL string) {
+	c.spools.remove(cloneURL)
+}
+
+// DownloadRepo downloads the repository tarball and extracts it to destDir. does it using worker pattern.
+// The tarball is spooled to disk first, so an interrupted download is resumed by the next call
+// and nothing is extracted until the whole tarball has arrived.
+func (c *Client) DownloadRepo(ctx context.Context, cloneURL, destDir string) error {
+	var tracker *progress.Tracker
+	if c.progress != nil {
+		tracker = progress.NewTracker(c.progress)
+		defer tracker.Stop()
+	}
+
+	if c.cache != nil {
+		return c.downloadRepoCached(ctx, cloneURL, destDir, tracker)
+	}
+
+	tarballURL, err := c.cloneURLToTarballURL(cloneURL)
+	if err != nil {
+		return fmt.Errorf("converting clone URL: %w", err)
+	}
+	c.logger.Info("Converted clone URL", "clone_url", cloneURL, "tarball_url", tarballURL)
+
+	spoolPath, err := c.downloadTarball(ctx, cloneURL, tarballURL, tracker)
 	if err != nil {
 		return err
 	}
-	defer tarball.Close()
+	// the complete tarball is never resumed, so it goes whether extraction works or not
+	defer c.spools.remove(cloneURL)
 
-	c.logger.Debug("tarball stream got successfully")
+	return c.extractFile(spoolPath, destDir, tracker)
+}
 
-	if err := os.MkdirAll(destDir, 0o755); err != nil {
-		return fmt.Errorf("creating destination directory: %w", err)
+// downloadRepoCached resolves the commit cloneURL points at and extracts its tarball from the
+// cache, downloading and caching it first when this commit has not been seen before
+func (c *Client) downloadRepoCached(ctx context.Context, cloneURL, destDir string, tracker *progress.Tracker) error {
+	repo, ref, err := ParseCloneURL(cloneURL)
+	if err != nil {
+		return fmt.Errorf("converting clone URL: %w", err)
 	}
 
-	c.logger.Debug("about to call extract tarball")
+	commit, err := c.resolveCommit(ctx, repo, ref)
+	if err != nil {
+		return err
+	}
+
+	f, ok, err := c.cache.Open(repo, commit)
+	if err != nil {
+		return err
+	}
+	if ok {
+		c.logger.Info("Using cached tarball", "repo", repo, "commit", commit)
+		err := c.extract(f, destDir, tracker)
+		f.Close()
+		if err == nil || !isCorrupt(err) {
+			return err
+		}
+		// a corrupt tarball would fail every later scan of the commit, so it is dropped and downloaded again
+		c.logger.Warn("Cached tarball failed to extract, downloading it again", "repo", repo, "commit", commit, "error", err)
+		if err := c.cache.Remove(repo, commit); err != nil {
+			return err
+		}
+		if err := emptyDir(destDir); err != nil {
+			return err
+		}
+	}
 
-	return extractTarballConcurrently(tarball, destDir, c.logger)
+	// the tarball of the commit rather than the ref, so it matches what it is cached as
+	tarballURL := fmt.Sprintf("%s/repos/%s/tarball/%s", c.apiBaseURL, repo, commit)
+	c.logger.Info("Converted clone URL", "clone_url", cloneURL, "tarball_url", tarballURL)
+
+	spoolPath, err := c.downloadTarball(ctx, cloneURL, tarballURL, tracker)
+	if err != nil {
+		return err
+	}
+	defer c.spools.remove(cloneURL)
+
+	// only a tarball that extracted cleanly is worth keeping
+	if err := c.extractFile(spoolPath, destDir, tracker); err != nil {
+		return err
+	}
+	if err := c.cache.Put(repo, commit, spoolPath); err != nil {
+		c.logger.Warn("Failed to cache tarball", "repo", repo, "commit", commit, "error", err)
+	}
+	return nil
 }
 
-func (c *Client) getTarballStream(cloneURL string) (io.ReadCloser, error) {
-	tarballURL, err := c.cloneURLToTarballURL(cloneURL)
+// emptyDir removes everything inside dir, leaving dir itself in place
+func emptyDir(dir string) error {
+	entries, err := os.ReadDir(dir)
 	if err != nil {
-		return nil, fmt.Errorf("converting clone URL: %w", err)
+		return fmt.Errorf("reading destination directory: %w", err)
 	}
-	c.logger.Info("Converted clone URL", "clone_url", cloneURL, "tarball_url", tarballURL)
+	for _, e := range entries {
+		if err := os.RemoveAll(filepath.Join(dir, e.Name())); err != nil {
+			return fmt.Errorf("cleaning destination directory: %w", err)
+		}
+	}
+	return nil
+}
 
-	req, err := http.NewRequest("GET", tarballURL, nil)
+// resolveCommit returns the commit SHA ref of repo points at, or the default branch's when ref is empty.
+// The ETag of the last answer is sent along, so an unchanged ref costs a 304 and no rate-limit quota.
+func (c *Client) resolveCommit(ctx context.Context, repo, ref string) (string, error) {
+	commitRef := ref
+	if commitRef == "" {
+		commitRef = "HEAD"
+	}
+
+	cached, known, err := c.cache.Ref(repo, commitRef)
 	if err != nil {
-		return nil, fmt.Errorf("creating request: %w", err)
+		return "", err
 	}
-	if c.token != "" {
-		req.Header.Set("Authorization", "Bearer "+c.token)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%s/repos/%s/commits/%s", c.apiBaseURL, repo, escapePath(commitRef)), nil)
+	if err != nil {
+		return "", fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Accept", "application/vnd.github.sha")
+	if known && cached.ETag != "" {
+		req.Header.Set("If-None-Match", cached.ETag)
 	}
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
-		return nil, fmt.Errorf("fetching tarball: %w", err)
+		return "", &NetworkError{Op: "resolving " + commitRef, Err: err}
 	}
+	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		defer resp.Body.Close()
-
-		if resp.StatusCode == http.StatusTooManyRequests {
-			retryAfter := 3 * time.Second // default
-			if val := resp.Header.Get("Retry-After"); val != "" {
-				if secs, err := strconv.Atoi(val); err == nil {
-					retryAfter = time.Duration(secs) * time.Second
-				}
-			}
-			return nil, &RetryAfterError{
-				Err:        fmt.Errorf("rate limited: 429 Too Many Requests"),
-				RetryAfter: retryAfter,
-			}
-		}
-		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		c.logger.Debug("Ref unchanged", "repo", repo, "ref", commitRef, "commit", cached.Commit)
+		return cached.Commit, nil
+	case http.StatusOK:
+	default:
+		return "", c.statusError(resp)
 	}
 
-	gzr, err := gzip.NewReader(resp.Body)
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1024))
 	if err != nil {
-		resp.Body.Close()
-		return nil, fmt.Errorf("creating gzip reader: %w", err)
-	}
-
-	return struct {
-		io.Reader
-		io.Closer
-	}{
-		Reader: gzr,
-		Closer: closer{gzr: gzr, body: resp.Body},
-	}, nil
-} // end of getTarballStream
-
-type closer struct {
-	gzr  *gzip.Reader
-	body io.Closer
+		return "", &NetworkError{Op: "resolving " + commitRef, Err: err}
+	}
+	commit := strings.TrimSpace(string(body))
+	if commit == "" {
+		return "", fmt.Errorf("resolving %s: empty commit SHA", commitRef)
+	}
+
+	if err := c.cache.SetRef(repo, commitRef, cache.Ref{Commit: commit, ETag: resp.Header.Get("ETag")}); err != nil {
+		c.logger.Warn("Failed to cache resolved ref", "repo", repo, "ref", commitRef, "error", err)
+	}
+	c.logger.Debug("Resolved ref", "repo", repo, "ref", commitRef, "commit", commit)
+	return commit, nil
 }
 
-func (c closer) Close() error {
-	gzrErr := c.gzr.Close()
-	bodyErr := c.body.Close()
-	if gzrErr != nil {
-		return gzrErr
+// extractFile extracts the gzipped tarball at path into destDir
+func (c *Client) extractFile(path, destDir string, tracker *progress.Tracker) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("opening spool file: %w", err)
 	}
-	return bodyErr
+	defer f.Close()
+	return c.extract(f, destDir, tracker)
+}
+
+// extract extracts the gzipped tarball read from r into destDir, counting the files in tracker
+func (c *Client) extract(r io.Reader, destDir string, tracker *progress.Tracker) error {
+	if err := os.MkdirAll(destDir, 0o755); err != nil {
+		return fmt.Errorf("creating destination directory: %w", err)
+	}
+
+	gzr, err := gzip.NewReader(r)
+	if err != nil {
+		return &ExtractionError{Err: fmt.Errorf("creating gzip reader: %w", err)}
+	}
+	defer gzr.Close()
+	c.logger.Debug("tarball stream got successfully")
+
+	c.logger.Debug("about to call extract tarball")
+
+	return extractTarballConcurrently(gzr, destDir, c.logger, tracker)
+}
+
+// statusError converts an unsuccessful response into one of the typed errors in errors.go.
+// Rate-limit responses become a *RetryAfterError and pause the limiter so other requests wait as well.
+func (c *Client) statusError(resp *http.Response) error {
+	if raErr := rateLimitError(resp, time.Now()); raErr != nil {
+		c.limiter.Pause(raErr.RetryAfter)
+		c.logger.Warn("Rate limited by GitHub", "status", resp.StatusCode, "retry_after_sec", raErr.RetryAfter.Seconds(), "error", raErr.Err)
+		return raErr
+	}
+
+	var reqURL string
+	if resp.Request != nil {
+		reqURL = resp.Request.URL.String()
+	}
+	switch resp.StatusCode {
+	case http.StatusNotFound:
+		return &NotFoundError{URL: reqURL}
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return &UnauthorizedError{StatusCode: resp.StatusCode, URL: reqURL}
+	}
+	return &HTTPStatusError{StatusCode: resp.StatusCode, URL: reqURL}
 }
 
 type extractTask struct {
@@ -226,7 +517,7 @@ type extractTask struct {
 	path string
 }
 
-func extractTarballConcurrently(r io.Reader, destDir string, log logger.Logger) error {
+func extractTarballConcurrently(r io.Reader, destDir string, log logger.Logger, tracker *progress.Tracker) error {
     const workerCount = 4
 
     tr := tar.NewReader(r)
@@ -241,9 +532,10 @@ func extractTarballConcurrently(r io.Reader, destDir string, log logger.Logger)
             for task := range tasks {
                 if err := writeFile(task.data, task.path); err != nil {
                     log.Error("Worker failed to write file", "worker", workerID, "path", task.path, "error", err)
-                    errChan <- fmt.Errorf("worker %d: %w", workerID, err)
+                    errChan <- &ExtractionError{Path: task.path, Err: err}
                     return
                 }
+                tracker.FileExtracted()
                 log.Debug("Extracted file", "worker", workerID, "path", task.path)
             }
         }(i)
@@ -258,7 +550,7 @@ func extractTarballConcurrently(r io.Reader, destDir string, log logger.Logger)
             log.Error("Failed to read tarball", "error", err)
             close(tasks)
             wg.Wait()
-            return fmt.Errorf("reading tarball: %w", err)
+            return &ExtractionError{Err: fmt.Errorf("reading tarball: %w", err)}
         }
 
         // log the header name for debugging
@@ -286,7 +578,7 @@ func extractTarballConcurrently(r io.Reader, destDir string, log logger.Logger)
             log.Error("Path traversal detected", "header_name", header.Name, "rel_path", relPath, "target_path", targetPath, "expected_prefix", expectedPrefix)
             close(tasks)
             wg.Wait()
-            return fmt.Errorf("illegal file path: %s (header: %s)", targetPath, header.Name)
+            return &ExtractionError{Path: header.Name, Err: fmt.Errorf("illegal file path: %s", targetPath)}
         }
 
         switch header.Typeflag {
@@ -295,7 +587,7 @@ func extractTarballConcurrently(r io.Reader, destDir string, log logger.Logger)
                 log.Error("Failed to create directory", "path", targetPath, "error", err)
                 close(tasks)
                 wg.Wait()
-                return fmt.Errorf("creating directory: %w", err)
+                return &ExtractionError{Path: targetPath, Err: fmt.Errorf("creating directory: %w", err)}
             }
             log.Debug("Created directory", "path", targetPath)
 
@@ -305,7 +597,7 @@ func extractTarballConcurrently(r io.Reader, destDir string, log logger.Logger)
                 log.Error("Failed to read file from tar", "path", targetPath, "error", err)
                 close(tasks)
                 wg.Wait()
-                return fmt.Errorf("reading file %s: %w", targetPath, err)
+                return &ExtractionError{Path: targetPath, Err: fmt.Errorf("reading file: %w", err)}
             }
             tasks <- extractTask{
                 data: buf.Bytes(),
@@ -355,15 +647,29 @@ func writeFile(data []byte, path string) error {
 
 // cloneURLToTarballURL converts a GitHub clone URL to a tarball URL
 var cloneURLToTarballURL = func(cloneURL string) (string, error) {
+	repo, ref, err := ParseCloneURL(cloneURL)
+	if err != nil {
+		return "", err
+	}
+	if ref != "" {
+		return fmt.Sprintf("%s/repos/%s/tarball/%s", apiBaseURL, repo, ref), nil
+	}
+	return fmt.Sprintf("%s/repos/%s/tarball", apiBaseURL, repo), nil
+}
+
+// ParseCloneURL splits a GitHub clone URL into the owner/repo path and the ref,
+// which is empty unless the URL names one as https://github.com/owner/repo/tree/<ref>
+func ParseCloneURL(cloneURL string) (repo, ref string, err error) {
 	if !strings.HasPrefix(cloneURL, "https://github.com/") {
-		return "", fmt.Errorf("invalid GitHub clone URL")
+		return "", "", fmt.Errorf("invalid GitHub clone URL")
 	}
 
 	path := strings.TrimPrefix(cloneURL, "https://github.com/")
+	path, ref, _ = strings.Cut(path, "/tree/")
 	path = strings.TrimSuffix(path, ".git")
 	if path == "" {
-		return "", fmt.Errorf("invalid repository path")
+		return "", "", fmt.Errorf("invalid repository path")
 	}
 
-	return fmt.Sprintf("https://api.github.com/repos/%s/tarball", path), nil
+	return path, ref, nil
 }
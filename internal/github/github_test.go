@@ -3,13 +3,30 @@ package github
 import (
 	"archive/tar"
 	"compress/gzip"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/babyfaceeasy/repo-scanner/internal/cache"
+	"github.com/babyfaceeasy/repo-scanner/internal/model"
+	"github.com/babyfaceeasy/repo-scanner/internal/progress"
 )
 
 type mockLogger struct {
@@ -39,6 +56,11 @@ func TestCloneURLToTarballURL(t *testing.T) {
 			cloneURL: "https://github.com/owner/repo",
 			wantURL:  "https://api.github.com/repos/owner/repo/tarball",
 		},
+		{
+			name:     "clone URL with ref",
+			cloneURL: "https://github.com/owner/repo/tree/0123abc",
+			wantURL:  "https://api.github.com/repos/owner/repo/tarball/0123abc",
+		},
 		{
 			name:     "invalid clone URL",
 			cloneURL: "https://gitlab.com/owner/repo.git",
@@ -99,7 +121,7 @@ func TestDownloadRepo(t *testing.T) {
 	defer func() { client.cloneURLToTarballURL = originalCloneURLToTarballURL }()
 
 	tmpDir := t.TempDir()
-	err := client.DownloadRepo("https://github.com/owner/repo.git", tmpDir)
+	err := client.DownloadRepo(context.Background(), "https://github.com/owner/repo.git", tmpDir)
 	if err != nil {
 		t.Fatalf("DownloadRepo() error = %v", err)
 	}
@@ -169,7 +191,7 @@ func TestDownloadRepo_WithMultipleFiles(t *testing.T) {
 	}
 
 	tmpDir := t.TempDir()
-	err := client.DownloadRepo("https://github.com/owner/repo.git", tmpDir)
+	err := client.DownloadRepo(context.Background(), "https://github.com/owner/repo.git", tmpDir)
 	if err != nil {
 		t.Fatalf("DownloadRepo() error: %v", err)
 	}
@@ -205,7 +227,7 @@ func TestDownloadRepo_Security_PathTraversal(t *testing.T) {
 	}
 
 	tmpDir := t.TempDir()
-	err := client.DownloadRepo("https://github.com/owner/repo.git", tmpDir)
+	err := client.DownloadRepo(context.Background(), "https://github.com/owner/repo.git", tmpDir)
 	if err == nil || !strings.Contains(err.Error(), "illegal file path") {
 		t.Fatalf("expected path traversal error, got: %v", err)
 	}
@@ -225,7 +247,7 @@ func TestDownloadRepo_429RetryAfter(t *testing.T) {
 	}
 
 	tmpDir := t.TempDir()
-	err := client.DownloadRepo("https://github.com/owner/repo.git", tmpDir)
+	err := client.DownloadRepo(context.Background(), "https://github.com/owner/repo.git", tmpDir)
 	if err == nil {
 		t.Fatal("expected RetryAfterError, got nil")
 	}
@@ -240,6 +262,768 @@ func TestDownloadRepo_429RetryAfter(t *testing.T) {
 	}
 }
 
+func TestSubmoduleCommit(t *testing.T) {
+	mockLog := &mockLogger{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/owner/repo/contents/libs/lib":
+			if got := r.URL.Query().Get("ref"); got != "v1.0" {
+				t.Errorf("ref = %q, want v1.0", got)
+			}
+			w.Write([]byte(`{"type":"submodule","sha":"abc123","submodule_git_url":"https://github.com/other/lib.git"}`))
+		case "/repos/owner/repo/contents/README.md":
+			w.Write([]byte(`{"type":"file","sha":"def456"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient("test-token", mockLog)
+	client.apiBaseURL = server.URL
+
+	sha, err := client.SubmoduleCommit(context.Background(), "https://github.com/owner/repo/tree/v1.0", "libs/lib")
+	if err != nil || sha != "abc123" {
+		t.Errorf("SubmoduleCommit() = %q, %v, want abc123", sha, err)
+	}
+	if _, err := client.SubmoduleCommit(context.Background(), "https://github.com/owner/repo.git", "README.md"); err == nil {
+		t.Error("SubmoduleCommit() expected error for a regular file")
+	}
+	if _, err := client.SubmoduleCommit(context.Background(), "https://github.com/owner/repo.git", "missing"); err == nil {
+		t.Error("SubmoduleCommit() expected error for a missing path")
+	}
+}
+
+func TestStatusErrors(t *testing.T) {
+	tests := []struct {
+		status    int
+		check     func(error) bool
+		retryable bool
+	}{
+		{status: 404, check: func(err error) bool { var e *NotFoundError; return errors.As(err, &e) }},
+		{status: 401, check: func(err error) bool { var e *UnauthorizedError; return errors.As(err, &e) && e.StatusCode == 401 }},
+		{status: 403, check: func(err error) bool { var e *UnauthorizedError; return errors.As(err, &e) && e.StatusCode == 403 }},
+		{status: 422, check: func(err error) bool { var e *HTTPStatusError; return errors.As(err, &e) && e.StatusCode == 422 }},
+		{status: 503, check: func(err error) bool { var e *HTTPStatusError; return errors.As(err, &e) && e.StatusCode == 503 }, retryable: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.status), func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			client := NewClient("test-token", &mockLogger{})
+			client.cloneURLToTarballURL = func(_ string) (string, error) {
+				return server.URL + "/repos/owner/repo/tarball", nil
+			}
+
+			err := client.DownloadRepo(context.Background(), "https://github.com/owner/repo.git", t.TempDir())
+			if !tt.check(err) {
+				t.Fatalf("DownloadRepo() error = %T %v, wrong type for %d", err, err, tt.status)
+			}
+			var r interface{ Retryable() bool }
+			if !errors.As(err, &r) || r.Retryable() != tt.retryable {
+				t.Errorf("Retryable() = %v, want %v", r != nil && r.Retryable(), tt.retryable)
+			}
+		})
+	}
+}
+
+func TestDownloadRepo_TruncatedTarball(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gzw := gzip.NewWriter(w)
+		tw := tar.NewWriter(gzw)
+		tw.WriteHeader(&tar.Header{Name: "repo-main/big.txt", Mode: 0o644, Size: 100000, Typeflag: tar.TypeReg})
+		tw.Write([]byte(strings.Repeat("x", 1000)))
+		gzw.Flush()
+		// the connection closes before the entry is complete
+	}))
+	defer server.Close()
+
+	client := NewClient("test-token", &mockLogger{})
+	client.cloneURLToTarballURL = func(_ string) (string, error) {
+		return server.URL + "/repos/owner/repo/tarball", nil
+	}
+
+	err := client.DownloadRepo(context.Background(), "https://github.com/owner/repo.git", t.TempDir())
+	var extractErr *ExtractionError
+	if !errors.As(err, &extractErr) {
+		t.Fatalf("DownloadRepo() error = %T %v, want *ExtractionError", err, err)
+	}
+	if !extractErr.Retryable() {
+		t.Errorf("Retryable() = false for a truncated stream: %v", err)
+	}
+}
+
+func TestRateLimitError(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	reset := strconv.FormatInt(now.Add(90*time.Second).Unix(), 10)
+
+	tests := []struct {
+		name      string
+		status    int
+		headers   map[string]string
+		body      string
+		wantDelay time.Duration // zero means not rate limited
+	}{
+		{name: "primary limit exhausted", status: 403, headers: map[string]string{"X-RateLimit-Remaining": "0", "X-RateLimit-Limit": "5000", "X-RateLimit-Reset": reset}, wantDelay: 91 * time.Second},
+		{name: "429 with primary headers", status: 429, headers: map[string]string{"X-RateLimit-Remaining": "0", "X-RateLimit-Reset": reset}, wantDelay: 91 * time.Second},
+		{name: "secondary limit with retry-after", status: 403, headers: map[string]string{"Retry-After": "30", "X-RateLimit-Remaining": "4000"}, wantDelay: 30 * time.Second},
+		{name: "retry-after as http date", status: 429, headers: map[string]string{"Retry-After": now.Add(time.Minute).UTC().Format(http.TimeFormat)}, wantDelay: time.Minute},
+		{name: "secondary limit in body", status: 403, body: `{"message":"You have exceeded a secondary rate limit."}`, wantDelay: time.Minute},
+		{name: "bare 429", status: 429, wantDelay: 3 * time.Second},
+		{name: "forbidden with quota left", status: 403, headers: map[string]string{"X-RateLimit-Remaining": "4000"}, body: `{"message":"Resource not accessible"}`},
+		{name: "server error", status: 503, headers: map[string]string{"Retry-After": "5"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &http.Response{StatusCode: tt.status, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(tt.body))}
+			for k, v := range tt.headers {
+				resp.Header.Set(k, v)
+			}
+
+			err := rateLimitError(resp, now)
+			if tt.wantDelay == 0 {
+				if err != nil {
+					t.Errorf("rateLimitError() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("rateLimitError() = nil, want *RetryAfterError")
+			}
+			if err.RetryAfter != tt.wantDelay {
+				t.Errorf("RetryAfter = %v, want %v", err.RetryAfter, tt.wantDelay)
+			}
+		})
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	var slept []time.Duration
+	limiter := NewRateLimiter(10, &mockLogger{})
+	limiter.now = func() time.Time { return now }
+	limiter.sleep = func(_ context.Context, d time.Duration) error { slept = append(slept, d); return nil }
+
+	update := func(remaining int) {
+		resp := &http.Response{Header: http.Header{}}
+		resp.Header.Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+		resp.Header.Set("X-RateLimit-Reset", strconv.FormatInt(now.Add(time.Minute).Unix(), 10))
+		limiter.Update(resp)
+	}
+
+	limiter.Wait()
+	update(500)
+	limiter.Wait()
+	if len(slept) != 0 {
+		t.Fatalf("Wait() slept %v with quota left", slept)
+	}
+
+	update(3)
+	limiter.Wait()
+	if len(slept) != 1 || slept[0] != time.Minute+time.Second {
+		t.Fatalf("Wait() slept %v, want until the reset", slept)
+	}
+
+	slept = nil
+	update(500)
+	limiter.Pause(20 * time.Second)
+	limiter.Wait()
+	if len(slept) != 1 || slept[0] != 20*time.Second {
+		t.Errorf("Wait() slept %v, want the 20s pause", slept)
+	}
+}
+
+func TestDownloadRepo_403RateLimit(t *testing.T) {
+	mockLog := &mockLogger{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Add(time.Minute).Unix(), 10))
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	limiter := NewRateLimiter(10, mockLog)
+	client := NewClient("test-token", mockLog, WithRateLimiter(limiter))
+	client.cloneURLToTarballURL = func(_ string) (string, error) {
+		return server.URL + "/repos/owner/repo/tarball", nil
+	}
+
+	err := client.DownloadRepo(context.Background(), "https://github.com/owner/repo.git", t.TempDir())
+	var raErr *RetryAfterError
+	if !errors.As(err, &raErr) {
+		t.Fatalf("DownloadRepo() error = %v, want *RetryAfterError", err)
+	}
+	if raErr.RetryAfter < 55*time.Second || raErr.RetryAfter > 62*time.Second {
+		t.Errorf("RetryAfter = %v, want about a minute", raErr.RetryAfter)
+	}
+
+	// the shared limiter now holds back other requests until the reset
+	var slept time.Duration
+	limiter.sleep = func(_ context.Context, d time.Duration) error { slept = d; return nil }
+	limiter.Wait()
+	if slept < 55*time.Second {
+		t.Errorf("limiter slept %v, want about a minute", slept)
+	}
+}
+
+func testTarball(t *testing.T) []byte {
+	t.Helper()
+	var buf strings.Builder
+	gzw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gzw)
+	content := strings.Repeat("resumable content ", 2000)
+	tw.WriteHeader(&tar.Header{Name: "repo-main/file.txt", Mode: 0o644, Size: int64(len(content)), Typeflag: tar.TypeReg})
+	tw.Write([]byte(content))
+	tw.Close()
+	gzw.Close()
+	return []byte(buf.String())
+}
+
+func TestDownloadRepo_Resume(t *testing.T) {
+	tarball := testTarball(t)
+	half := len(tarball) / 2
+
+	tests := []struct {
+		name       string
+		etag       string
+		honorRange bool
+		wantRange  string // Range header expected on the second request
+	}{
+		{name: "resumes with range", etag: `"v1"`, honorRange: true, wantRange: fmt.Sprintf("bytes=%d-", half)},
+		{name: "server ignores range", etag: `"v1"`, honorRange: false, wantRange: fmt.Sprintf("bytes=%d-", half)},
+		{name: "weak etag restarts", etag: `W/"v1"`, honorRange: true, wantRange: ""},
+		{name: "no etag restarts", etag: "", honorRange: true, wantRange: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests []*http.Request
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests = append(requests, r)
+				if tt.etag != "" {
+					w.Header().Set("ETag", tt.etag)
+				}
+				if len(requests) == 1 {
+					// promise the whole tarball but drop the connection halfway
+					w.Header().Set("Content-Length", strconv.Itoa(len(tarball)))
+					w.Write(tarball[:half])
+					return
+				}
+				if tt.honorRange && r.Header.Get("Range") != "" && r.Header.Get("If-Range") == tt.etag {
+					w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", half, len(tarball)-1, len(tarball)))
+					w.WriteHeader(http.StatusPartialContent)
+					w.Write(tarball[half:])
+					return
+				}
+				w.Write(tarball)
+			}))
+			defer server.Close()
+
+			client := NewClient("test-token", &mockLogger{})
+			client.spoolDir = t.TempDir()
+			client.cloneURLToTarballURL = func(_ string) (string, error) {
+				return server.URL + "/repos/owner/repo/tarball", nil
+			}
+
+			destDir := t.TempDir()
+			err := client.DownloadRepo(context.Background(), "https://github.com/owner/repo.git", destDir)
+			var netErr *NetworkError
+			if !errors.As(err, &netErr) || !netErr.Retryable() {
+				t.Fatalf("first DownloadRepo() error = %v, want a retryable *NetworkError", err)
+			}
+			if _, err := os.Stat(filepath.Join(destDir, "file.txt")); !os.IsNotExist(err) {
+				t.Fatalf("file extracted from an incomplete download")
+			}
+
+			if err := client.DownloadRepo(context.Background(), "https://github.com/owner/repo.git", destDir); err != nil {
+				t.Fatalf("second DownloadRepo() error = %v", err)
+			}
+			if got := requests[1].Header.Get("Range"); got != tt.wantRange {
+				t.Errorf("Range = %q, want %q", got, tt.wantRange)
+			}
+			if tt.wantRange != "" && requests[1].Header.Get("If-Range") != tt.etag {
+				t.Errorf("If-Range = %q, want %q", requests[1].Header.Get("If-Range"), tt.etag)
+			}
+			assertFileContent(t, filepath.Join(destDir, "file.txt"), strings.Repeat("resumable content ", 2000))
+
+			// the spool is removed once the tarball has been extracted
+			left, _ := os.ReadDir(client.spoolDir)
+			if len(left) != 0 {
+				t.Errorf("spool directory holds %d files, want none", len(left))
+			}
+		})
+	}
+}
+
+func TestDownloadRepo_SpoolCleanup(t *testing.T) {
+	tarball := testTarball(t)
+	tests := []struct {
+		name      string
+		etag      string
+		status    int
+		wantSpool bool // whether the failed download is kept for resuming
+	}{
+		{name: "not found", status: http.StatusNotFound},
+		{name: "interrupted without etag", status: http.StatusOK},
+		{name: "interrupted with etag", etag: `"v1"`, status: http.StatusOK, wantSpool: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.etag != "" {
+					w.Header().Set("ETag", tt.etag)
+				}
+				if tt.status != http.StatusOK {
+					w.WriteHeader(tt.status)
+					return
+				}
+				w.Header().Set("Content-Length", strconv.Itoa(len(tarball)))
+				w.Write(tarball[:len(tarball)/2])
+			}))
+			defer server.Close()
+
+			client := NewClient("test-token", &mockLogger{})
+			client.spoolDir = t.TempDir()
+			client.cloneURLToTarballURL = func(_ string) (string, error) {
+				return server.URL + "/repos/owner/repo/tarball", nil
+			}
+
+			if err := client.DownloadRepo(context.Background(), "https://github.com/owner/repo.git", t.TempDir()); err == nil {
+				t.Fatal("DownloadRepo() expected error")
+			}
+			left, _ := os.ReadDir(client.spoolDir)
+			if got := len(left) == 1; got != tt.wantSpool || len(left) > 1 {
+				t.Fatalf("spool directory holds %d files after the failure, want spool kept = %v", len(left), tt.wantSpool)
+			}
+
+			client.DiscardDownload("https://github.com/owner/repo.git")
+			if left, _ := os.ReadDir(client.spoolDir); len(left) != 0 {
+				t.Errorf("spool directory holds %d files after DiscardDownload, want none", len(left))
+			}
+		})
+	}
+}
+
+func TestDownloadRepo_Cache(t *testing.T) {
+	tarball := testTarball(t)
+	commit := "0123456789abcdef0123456789abcdef01234567"
+	var commitRequests, tarballRequests int
+	var ifNoneMatch []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/owner/repo/commits/main":
+			commitRequests++
+			ifNoneMatch = append(ifNoneMatch, r.Header.Get("If-None-Match"))
+			if r.Header.Get("Accept") != "application/vnd.github.sha" {
+				t.Errorf("Accept = %q, want application/vnd.github.sha", r.Header.Get("Accept"))
+			}
+			if r.Header.Get("If-None-Match") == `"sha-etag"` {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Header().Set("ETag", `"sha-etag"`)
+			w.Write([]byte(commit))
+		case "/repos/owner/repo/tarball/" + commit:
+			tarballRequests++
+			w.Write(tarball)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	tarballCache, err := cache.New(t.TempDir(), 0, &mockLogger{})
+	if err != nil {
+		t.Fatalf("cache.New() error = %v", err)
+	}
+	mockLog := &mockLogger{}
+	client := NewClient("test-token", mockLog, WithCache(tarballCache))
+	client.apiBaseURL = server.URL
+	client.spoolDir = t.TempDir()
+
+	for i := 0; i < 2; i++ {
+		destDir := t.TempDir()
+		if err := client.DownloadRepo(context.Background(), "https://github.com/owner/repo/tree/main", destDir); err != nil {
+			t.Fatalf("DownloadRepo() #%d error = %v", i+1, err)
+		}
+		assertFileContent(t, filepath.Join(destDir, "file.txt"), strings.Repeat("resumable content ", 2000))
+	}
+
+	if commitRequests != 2 || tarballRequests != 1 {
+		t.Errorf("requests = %d commit, %d tarball, want 2 and 1", commitRequests, tarballRequests)
+	}
+	if ifNoneMatch[0] != "" || ifNoneMatch[1] != `"sha-etag"` {
+		t.Errorf("If-None-Match = %q, want none then the stored ETag", ifNoneMatch)
+	}
+	if !containsLog(mockLog.logs, "Using cached tarball") {
+		t.Errorf("Expected a cache hit to be logged, got logs: %v", mockLog.logs)
+	}
+
+	// a failure to write the extracted files leaves the cached tarball alone
+	blockedDir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(blockedDir, "file.txt"), 0o755); err != nil {
+		t.Fatalf("creating directory: %v", err)
+	}
+	var extErr *ExtractionError
+	if err := client.DownloadRepo(context.Background(), "https://github.com/owner/repo/tree/main", blockedDir); !errors.As(err, &extErr) {
+		t.Fatalf("DownloadRepo() into a blocked path error = %v, want *ExtractionError", err)
+	}
+	if _, ok, _ := tarballCache.Open("owner/repo", commit); !ok || tarballRequests != 1 {
+		t.Errorf("cached tarball evicted after a file system error, %d tarball requests", tarballRequests)
+	}
+
+	// a corrupt cached tarball is dropped and downloaded again instead of failing every scan
+	f, ok, err := tarballCache.Open("owner/repo", commit)
+	if err != nil || !ok {
+		t.Fatalf("Open() = %v, %v, want a hit", ok, err)
+	}
+	path := f.Name()
+	f.Close()
+	if err := os.WriteFile(path, []byte("not a tarball"), 0o644); err != nil {
+		t.Fatalf("corrupting cached tarball: %v", err)
+	}
+	destDir := t.TempDir()
+	if err := client.DownloadRepo(context.Background(), "https://github.com/owner/repo/tree/main", destDir); err != nil {
+		t.Fatalf("DownloadRepo() with a corrupt cached tarball error = %v", err)
+	}
+	assertFileContent(t, filepath.Join(destDir, "file.txt"), strings.Repeat("resumable content ", 2000))
+	if tarballRequests != 2 {
+		t.Errorf("tarball requests = %d, want the corrupt tarball downloaded again", tarballRequests)
+	}
+	if !containsLog(mockLog.logs, "Cached tarball failed to extract") {
+		t.Errorf("Expected the corrupt tarball to be logged, got logs: %v", mockLog.logs)
+	}
+}
+
+func containsLog(logs []string, substr string) bool {
+	for _, log := range logs {
+		if strings.Contains(log, substr) {
+			return true
+		}
+	}
+	return false
+}
+
+type recordingReporter struct {
+	done []progress.Stats
+}
+
+func (r *recordingReporter) Report(s progress.Stats) {}
+func (r *recordingReporter) Done(s progress.Stats)   { r.done = append(r.done, s) }
+
+func TestDownloadRepo_Progress(t *testing.T) {
+	tarball := testTarball(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", strconv.Itoa(len(tarball)))
+		w.Write(tarball)
+	}))
+	defer server.Close()
+
+	reporter := &recordingReporter{}
+	client := NewClient("test-token", &mockLogger{}, WithProgress(reporter))
+	client.spoolDir = t.TempDir()
+	client.cloneURLToTarballURL = func(_ string) (string, error) {
+		return server.URL + "/repos/owner/repo/tarball", nil
+	}
+
+	if err := client.DownloadRepo(context.Background(), "https://github.com/owner/repo.git", t.TempDir()); err != nil {
+		t.Fatalf("DownloadRepo() error = %v", err)
+	}
+	if len(reporter.done) != 1 {
+		t.Fatalf("Done() called %d times, want 1", len(reporter.done))
+	}
+	s := reporter.done[0]
+	if s.Received != int64(len(tarball)) || s.Total != int64(len(tarball)) || s.Files != 1 {
+		t.Errorf("final stats = %+v, want %d of %d bytes and 1 file", s, len(tarball), len(tarball))
+	}
+}
+
+func TestRepository(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/owner/repo":
+			w.Write([]byte(`{"full_name":"owner/repo","default_branch":"develop","size":5120,"visibility":"internal","private":true,"archived":true,"fork":false,"permissions":{"admin":false,"maintain":false,"push":true,"triage":true,"pull":true}}`))
+		case "/repos/owner/old":
+			// GitHub Enterprise versions without the visibility field
+			w.Write([]byte(`{"full_name":"owner/old","default_branch":"master","size":10,"private":true}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient("test-token", &mockLogger{})
+	client.apiBaseURL = server.URL
+
+	got, err := client.Repository(context.Background(), "https://github.com/owner/repo/tree/feature")
+	if err != nil {
+		t.Fatalf("Repository() error = %v", err)
+	}
+	want := model.Repository{FullName: "owner/repo", DefaultBranch: "develop", SizeKB: 5120, Visibility: "internal", Archived: true, Access: "push"}
+	if *got != want {
+		t.Errorf("Repository() = %+v, want %+v", *got, want)
+	}
+
+	got, err = client.Repository(context.Background(), "https://github.com/owner/old.git")
+	if err != nil || got.Visibility != "private" || got.Access != "" {
+		t.Errorf("Repository() = %+v, %v, want private with no access reported", got, err)
+	}
+
+	_, err = client.Repository(context.Background(), "https://github.com/owner/missing.git")
+	var notFound *NotFoundError
+	if !errors.As(err, &notFound) {
+		t.Errorf("Repository() error = %v, want *NotFoundError", err)
+	}
+}
+
+func TestScanTree(t *testing.T) {
+	var truncated bool
+	var requested string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = r.URL.RequestURI()
+		w.Write([]byte(`{"sha":"abc123","truncated":` + strconv.FormatBool(truncated) + `,"tree":[
+			{"path":"docs","type":"tree"},
+			{"path":"docs/big.pdf","type":"blob","sha":"b1","size":3000},
+			{"path":"art/cover.psd","type":"blob","size":3000},
+			{"path":"small.txt","type":"blob","size":10},
+			{"path":"vendor/lib","type":"commit"}
+		]}`))
+	}))
+	defer server.Close()
+
+	client := NewClient("test-token", &mockLogger{})
+	client.apiBaseURL = server.URL
+	threshold := func(path string) (string, int64) {
+		if strings.HasSuffix(path, ".psd") {
+			return "*.psd", 5000
+		}
+		return "", 1000
+	}
+
+	out, err := client.ScanTree(context.Background(), "owner/repo", "release/v1", threshold)
+	if err != nil {
+		t.Fatalf("ScanTree() error = %v", err)
+	}
+	if requested != "/repos/owner/repo/git/trees/release/v1?recursive=1" {
+		t.Errorf("requested %s", requested)
+	}
+	want := []model.FileInfo{{Name: filepath.FromSlash("docs/big.pdf"), Size: 3000, Threshold: 1000, BlobSHA: "b1"}}
+	if out.Total != 1 || len(out.Files) != 1 || out.Files[0] != want[0] {
+		t.Errorf("ScanTree() = %+v, want %+v", out.Files, want)
+	}
+
+	truncated = true
+	if _, err := client.ScanTree(context.Background(), "owner/repo", "main", threshold); !errors.Is(err, ErrTreeTruncated) {
+		t.Errorf("ScanTree() error = %v, want ErrTreeTruncated", err)
+	}
+}
+
+func TestParseContentRange(t *testing.T) {
+	tests := []struct {
+		val       string
+		wantStart int64
+		wantSize  int64
+		wantErr   bool
+	}{
+		{val: "bytes 100-199/200", wantStart: 100, wantSize: 200},
+		{val: "bytes 0-99/*", wantStart: 0, wantSize: -1},
+		{val: "bytes */200", wantErr: true},
+		{val: "items 0-1/2", wantErr: true},
+		{val: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		start, size, err := parseContentRange(tt.val)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseContentRange(%q) error = %v, wantErr %v", tt.val, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && (start != tt.wantStart || size != tt.wantSize) {
+			t.Errorf("parseContentRange(%q) = %d, %d, want %d, %d", tt.val, start, size, tt.wantStart, tt.wantSize)
+		}
+	}
+}
+
+// writePEM writes a PEM block of the given type to a file in dir and returns its path
+func writePEM(t *testing.T, dir, name, blockType string, der []byte) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600); err != nil {
+		t.Fatalf("writing %s: %v", name, err)
+	}
+	return path
+}
+
+func TestNewTransport_TLS(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.PeerCertificates) > 0 {
+			w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+		}
+	}))
+	server.StartTLS()
+	defer server.Close()
+	dir := t.TempDir()
+	caFile := writePEM(t, dir, "ca.pem", "CERTIFICATE", server.Certificate().Raw)
+
+	get := func(cfg TransportConfig) (string, error) {
+		transport, err := NewTransport(cfg)
+		if err != nil {
+			return "", err
+		}
+		resp, err := (&http.Client{Transport: transport}).Get(server.URL)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		return string(body), err
+	}
+
+	if _, err := get(TransportConfig{}); err == nil {
+		t.Error("request to a server with an unknown CA succeeded")
+	}
+	if _, err := get(TransportConfig{CAFile: caFile}); err != nil {
+		t.Errorf("request trusting the custom CA error = %v", err)
+	}
+
+	// a server that requires a client certificate
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generating key: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "repo-scanner"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("creating client certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("encoding client key: %v", err)
+	}
+	certFile := writePEM(t, dir, "client.pem", "CERTIFICATE", certDER)
+	keyFile := writePEM(t, dir, "client-key.pem", "EC PRIVATE KEY", keyDER)
+
+	clientCert, _ := x509.ParseCertificate(certDER)
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCert)
+	server.TLS.ClientAuth = tls.RequireAndVerifyClientCert
+	server.TLS.ClientCAs = clientCAs
+
+	if _, err := get(TransportConfig{CAFile: caFile}); err == nil {
+		t.Error("request without a client certificate succeeded")
+	}
+	name, err := get(TransportConfig{CAFile: caFile, ClientCertFile: certFile, ClientKeyFile: keyFile})
+	if err != nil || name != "repo-scanner" {
+		t.Errorf("request with a client certificate = %q, %v, want repo-scanner", name, err)
+	}
+
+	if _, err := NewTransport(TransportConfig{ClientCertFile: certFile}); err == nil {
+		t.Error("NewTransport() accepted a client certificate without a key")
+	}
+	if _, err := NewTransport(TransportConfig{CAFile: keyFile}); err == nil {
+		t.Error("NewTransport() accepted a CA file without certificates")
+	}
+	if _, err := NewTransport(TransportConfig{ReadTimeout: 20 * time.Second}); err == nil {
+		t.Error("NewTransport() accepted an idle connection timeout longer than the read timeout")
+	}
+}
+
+func TestNewTransport_Proxy(t *testing.T) {
+	var proxied []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// a forward proxy receives the absolute URL of the target
+		proxied = append(proxied, r.URL.String())
+		w.Write([]byte("via proxy"))
+	}))
+	defer proxy.Close()
+
+	transport, err := NewTransport(TransportConfig{HTTPProxy: proxy.URL, HTTPSProxy: proxy.URL, NoProxy: "direct.test,.internal.test"})
+	if err != nil {
+		t.Fatalf("NewTransport() error = %v", err)
+	}
+
+	resp, err := (&http.Client{Transport: transport}).Get("http://api.github.test/repos/owner/repo")
+	if err != nil {
+		t.Fatalf("request through the proxy error = %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "via proxy" || len(proxied) != 1 || proxied[0] != "http://api.github.test/repos/owner/repo" {
+		t.Errorf("proxy saw %v and answered %q", proxied, body)
+	}
+
+	tests := []struct {
+		url       string
+		wantProxy bool
+	}{
+		{url: "https://api.github.com/repos/owner/repo", wantProxy: true},
+		{url: "https://direct.test/repos", wantProxy: false},
+		{url: "https://git.internal.test/repos", wantProxy: false},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest("GET", tt.url, nil)
+		got, err := transport.Proxy(req)
+		if err != nil {
+			t.Errorf("Proxy(%s) error = %v", tt.url, err)
+			continue
+		}
+		if (got != nil) != tt.wantProxy {
+			t.Errorf("Proxy(%s) = %v, want proxied %v", tt.url, got, tt.wantProxy)
+		}
+	}
+}
+
+func TestNewTransport_Timeouts(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/stall-body" {
+			w.Write([]byte("partial"))
+			w.(http.Flusher).Flush()
+		}
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	transport, err := NewTransport(TransportConfig{ResponseHeaderTimeout: 50 * time.Millisecond, IdleConnTimeout: 10 * time.Millisecond, ReadTimeout: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("NewTransport() error = %v", err)
+	}
+	client := NewClient("test-token", &mockLogger{}, WithTransport(transport))
+
+	for _, path := range []string{"/stall-headers", "/stall-body"} {
+		client.cloneURLToTarballURL = func(_ string) (string, error) {
+			return server.URL + path, nil
+		}
+		done := make(chan error, 1)
+		go func() {
+			done <- client.DownloadRepo(context.Background(), "https://github.com/owner/repo.git", t.TempDir())
+		}()
+
+		select {
+		case err := <-done:
+			var netErr *NetworkError
+			if !errors.As(err, &netErr) || !netErr.Retryable() {
+				t.Errorf("%s: DownloadRepo() error = %v, want a retryable *NetworkError", path, err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s: DownloadRepo() still hanging on a stalled server", path)
+		}
+	}
+}
+
 func assertFileContent(t *testing.T, path string, expected string) {
 	t.Helper()
 	data, err := os.ReadFile(path)
@@ -250,3 +1034,35 @@ func assertFileContent(t *testing.T, path string, expected string) {
 		t.Errorf("File content at %s = %q, want %q", path, data, expected)
 	}
 }
+
+func TestClientContext(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// hold the response until the client gives up
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	client := NewClient("test-token", &mockLogger{})
+	client.apiBaseURL = server.URL
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := client.SubmoduleCommit(ctx, "https://github.com/owner/repo.git", "libs/lib")
+	var netErr *NetworkError
+	if !errors.As(err, &netErr) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("SubmoduleCommit() error = %v, want a *NetworkError caused by the deadline", err)
+	}
+
+	// a pause of the rate limiter gives up with the context as well
+	client.limiter.Pause(time.Hour)
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := client.SubmoduleCommit(ctx, "https://github.com/owner/repo.git", "libs/lib"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("SubmoduleCommit() while paused error = %v, want the deadline", err)
+	}
+}
@@ -0,0 +1,197 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/babyfaceeasy/repo-scanner/pkg/logger"
+)
+
+const (
+	// DefaultRateLimitThreshold is the remaining quota below which requests are paused
+	DefaultRateLimitThreshold = 10
+	// defaultRetryAfter is the wait after a 429 that carries no rate-limit headers
+	defaultRetryAfter = 3 * time.Second
+	// secondaryRetryAfter is the wait GitHub asks for after a secondary rate limit without Retry-After
+	secondaryRetryAfter = time.Minute
+	// resetMargin is added to X-RateLimit-Reset to absorb clock skew
+	resetMargin = time.Second
+)
+
+// rateLimit holds the primary rate-limit headers of a response
+type rateLimit struct {
+	limit     int
+	remaining int
+	reset     time.Time
+	resource  string
+}
+
+// parseRateLimit reads the X-RateLimit-* headers, reporting false when they are absent
+func parseRateLimit(h http.Header) (rateLimit, bool) {
+	remaining, err := strconv.Atoi(h.Get("X-RateLimit-Remaining"))
+	if err != nil {
+		return rateLimit{}, false
+	}
+	rl := rateLimit{remaining: remaining, resource: h.Get("X-RateLimit-Resource")}
+	rl.limit, _ = strconv.Atoi(h.Get("X-RateLimit-Limit"))
+	if secs, err := strconv.ParseInt(h.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+		rl.reset = time.Unix(secs, 0)
+	}
+	return rl, true
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds or as an HTTP date
+func parseRetryAfter(h http.Header, now time.Time) (time.Duration, bool) {
+	val := h.Get("Retry-After")
+	if val == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(val); err == nil {
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(val); err == nil {
+		return max(t.Sub(now), 0), true
+	}
+	return 0, false
+}
+
+// rateLimitError returns a *RetryAfterError when resp reports a primary or secondary
+// rate limit, or nil otherwise. GitHub signals both with 403 as well as 429.
+func rateLimitError(resp *http.Response, now time.Time) *RetryAfterError {
+	if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusForbidden {
+		return nil
+	}
+
+	if retryAfter, ok := parseRetryAfter(resp.Header, now); ok {
+		return &RetryAfterError{
+			StatusCode: resp.StatusCode,
+			Err:        fmt.Errorf("rate limited: %d %s", resp.StatusCode, http.StatusText(resp.StatusCode)),
+			RetryAfter: retryAfter,
+		}
+	}
+
+	if rl, ok := parseRateLimit(resp.Header); ok && rl.remaining == 0 {
+		retryAfter := defaultRetryAfter
+		if !rl.reset.IsZero() {
+			retryAfter = max(rl.reset.Sub(now), 0) + resetMargin
+		}
+		return &RetryAfterError{
+			StatusCode: resp.StatusCode,
+			Err:        fmt.Errorf("rate limit exhausted for %s: %d requests per hour", resourceName(rl.resource), rl.limit),
+			RetryAfter: retryAfter,
+		}
+	}
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return &RetryAfterError{
+			StatusCode: resp.StatusCode,
+			Err:        fmt.Errorf("rate limited: 429 Too Many Requests"),
+			RetryAfter: defaultRetryAfter,
+		}
+	}
+
+	// secondary limits may come as a bare 403 whose message is the only clue
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if strings.Contains(strings.ToLower(string(body)), "secondary rate limit") {
+		return &RetryAfterError{
+			StatusCode: resp.StatusCode,
+			Err:        fmt.Errorf("secondary rate limit exceeded"),
+			RetryAfter: secondaryRetryAfter,
+		}
+	}
+	return nil
+}
+
+func resourceName(resource string) string {
+	if resource == "" {
+		return "core"
+	}
+	return resource
+}
+
+// RateLimiter pauses requests while the quota reported by GitHub is low or after a
+// rate-limit response. Share one limiter between every client using the same token.
+type RateLimiter struct {
+	mu         sync.Mutex
+	threshold  int
+	remaining  int // -1 until a response has been seen
+	reset      time.Time
+	pauseUntil time.Time
+	logger     logger.Logger
+	now        func() time.Time
+	sleep      func(ctx context.Context, d time.Duration) error
+}
+
+// NewRateLimiter creates a RateLimiter that pauses when fewer than threshold requests remain
+func NewRateLimiter(threshold int, logger logger.Logger) *RateLimiter {
+	return &RateLimiter{
+		threshold: threshold,
+		remaining: -1,
+		logger:    logger,
+		now:       time.Now,
+		sleep:     sleepContext,
+	}
+}
+
+// Wait blocks until a request may be sent
+func (l *RateLimiter) Wait() {
+	l.WaitContext(context.Background())
+}
+
+// WaitContext is Wait that gives up when ctx is done, returning its error
+func (l *RateLimiter) WaitContext(ctx context.Context) error {
+	l.mu.Lock()
+	now := l.now()
+	remaining := l.remaining
+	until := l.pauseUntil
+	if remaining >= 0 && remaining < l.threshold && l.reset.After(until) {
+		until = l.reset
+	}
+	l.mu.Unlock()
+
+	if wait := until.Sub(now); wait > 0 {
+		l.logger.Warn("Rate limit quota low, pausing requests", "remaining", remaining, "wait_sec", wait.Seconds())
+		return l.sleep(ctx, wait)
+	}
+	return nil
+}
+
+// sleepContext waits for d or until ctx is done
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// Update records the rate-limit state reported by a response
+func (l *RateLimiter) Update(resp *http.Response) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if rl, ok := parseRateLimit(resp.Header); ok {
+		l.remaining = rl.remaining
+		l.reset = rl.reset.Add(resetMargin)
+		l.logger.Debug("Rate limit updated", "resource", resourceName(rl.resource), "remaining", rl.remaining, "limit", rl.limit)
+	}
+}
+
+// Pause holds every request back for d
+func (l *RateLimiter) Pause(d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if until := l.now().Add(d); until.After(l.pauseUntil) {
+		l.pauseUntil = until
+	}
+}
@@ -4,6 +4,7 @@ import "time"
 
 // RetryAfterError represents a 429 rate-limit response with retry time.
 type RetryAfterError struct {
+	StatusCode int // 429, or 403 for a limit GitHub reports as forbidden
 	Err        error
 	RetryAfter time.Duration
 }
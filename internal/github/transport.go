@@ -0,0 +1,145 @@
+package github
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+// TransportConfig tunes the connections a Client makes. Zero durations take the value in DefaultTransportConfig.
+type TransportConfig struct {
+	ConnectTimeout        time.Duration // Establishing the TCP connection
+	TLSHandshakeTimeout   time.Duration // Completing the TLS handshake
+	ResponseHeaderTimeout time.Duration // Waiting for response headers once the request is sent
+	IdleConnTimeout       time.Duration // Keeping an unused connection open for reuse, shorter than ReadTimeout
+	ReadTimeout           time.Duration // Longest a connection may go without receiving data, e.g. mid-download
+
+	HTTPSProxy string // Proxy for https requests, as in HTTPS_PROXY
+	HTTPProxy  string // Proxy for http requests, as in HTTP_PROXY
+	NoProxy    string // Hosts reached directly, as in NO_PROXY
+
+	CAFile         string // PEM bundle of certificate authorities trusted in addition to the system ones
+	ClientCertFile string // PEM client certificate presented to servers that ask for one
+	ClientKeyFile  string // PEM private key of ClientCertFile
+}
+
+// DefaultTransportConfig holds the timeouts used for unset TransportConfig fields
+var DefaultTransportConfig = TransportConfig{
+	ConnectTimeout:        10 * time.Second,
+	TLSHandshakeTimeout:   10 * time.Second,
+	ResponseHeaderTimeout: 30 * time.Second,
+	IdleConnTimeout:       30 * time.Second,
+	ReadTimeout:           60 * time.Second,
+}
+
+// WithTransport makes the client send its requests through rt, e.g. one built by NewTransport
+func WithTransport(rt http.RoundTripper) Option {
+	return func(c *Client) {
+		c.httpClient.Transport = rt
+	}
+}
+
+// NewTransport builds an HTTP transport from cfg. The proxy settings are used as given rather
+// than read from the environment, so a caller loading them from .env gets the same behaviour.
+func NewTransport(cfg TransportConfig) (*http.Transport, error) {
+	cfg = cfg.withDefaults()
+	if cfg.IdleConnTimeout >= cfg.ReadTimeout {
+		return nil, fmt.Errorf("idle connection timeout %s must be shorter than the read timeout %s", cfg.IdleConnTimeout, cfg.ReadTimeout)
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if cfg.CAFile != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading CA file: %w", err)
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA file %s", cfg.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if cfg.ClientCertFile != "" || cfg.ClientKeyFile != "" {
+		if cfg.ClientCertFile == "" || cfg.ClientKeyFile == "" {
+			return nil, fmt.Errorf("client certificate and key must be given together")
+		}
+		cert, err := tls.LoadX509KeyPair(cfg.ClientCertFile, cfg.ClientKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("loading client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	proxy := (&httpproxy.Config{
+		HTTPSProxy: cfg.HTTPSProxy,
+		HTTPProxy:  cfg.HTTPProxy,
+		NoProxy:    cfg.NoProxy,
+	}).ProxyFunc()
+
+	dialer := &net.Dialer{Timeout: cfg.ConnectTimeout, KeepAlive: 30 * time.Second}
+	return &http.Transport{
+		Proxy: func(req *http.Request) (*url.URL, error) {
+			return proxy(req.URL)
+		},
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			conn, err := dialer.DialContext(ctx, network, addr)
+			if err != nil {
+				return nil, err
+			}
+			return &deadlineConn{Conn: conn, timeout: cfg.ReadTimeout}, nil
+		},
+		TLSClientConfig:       tlsConfig,
+		TLSHandshakeTimeout:   cfg.TLSHandshakeTimeout,
+		ResponseHeaderTimeout: cfg.ResponseHeaderTimeout,
+		IdleConnTimeout:       cfg.IdleConnTimeout,
+		MaxIdleConns:          10,
+		ForceAttemptHTTP2:     true,
+	}, nil
+}
+
+func (cfg TransportConfig) withDefaults() TransportConfig {
+	def := DefaultTransportConfig
+	if cfg.ConnectTimeout == 0 {
+		cfg.ConnectTimeout = def.ConnectTimeout
+	}
+	if cfg.TLSHandshakeTimeout == 0 {
+		cfg.TLSHandshakeTimeout = def.TLSHandshakeTimeout
+	}
+	if cfg.ResponseHeaderTimeout == 0 {
+		cfg.ResponseHeaderTimeout = def.ResponseHeaderTimeout
+	}
+	if cfg.IdleConnTimeout == 0 {
+		cfg.IdleConnTimeout = def.IdleConnTimeout
+	}
+	if cfg.ReadTimeout == 0 {
+		cfg.ReadTimeout = def.ReadTimeout
+	}
+	return cfg
+}
+
+// deadlineConn fails a read that receives nothing for timeout, so a stalled download
+// becomes a timeout error instead of hanging forever. The transport keeps reading from
+// idle pooled connections as well, which is why NewTransport requires IdleConnTimeout to
+// be shorter: the pool then closes an idle connection before its read deadline passes.
+type deadlineConn struct {
+	net.Conn
+	timeout time.Duration
+}
+
+func (c *deadlineConn) Read(p []byte) (int, error) {
+	if err := c.Conn.SetReadDeadline(time.Now().Add(c.timeout)); err != nil {
+		return 0, err
+	}
+	return c.Conn.Read(p)
+}
@@ -0,0 +1,85 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+
+	"github.com/babyfaceeasy/repo-scanner/internal/model"
+)
+
+// ErrTreeTruncated is returned by ScanTree when GitHub cut the tree short because the
+// repository has too many files; the tarball has to be scanned instead
+var ErrTreeTruncated = errors.New("git tree truncated by GitHub")
+
+// ThresholdFunc returns the pattern of the size rule that applies to a slash separated path,
+// empty for the default, and the threshold in bytes, zero for no limit
+type ThresholdFunc func(path string) (rule string, threshold int64)
+
+// ScanTree reports the files of repo at ref that are larger than their threshold, reading the
+// paths and sizes from the Git Trees API in a single request instead of downloading the contents
+func (c *Client) ScanTree(ctx context.Context, repo, ref string, threshold ThresholdFunc) (*model.Output, error) {
+	treeURL := fmt.Sprintf("%s/repos/%s/git/trees/%s?recursive=1", c.apiBaseURL, repo, escapePath(ref))
+	req, err := http.NewRequestWithContext(ctx, "GET", treeURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, &NetworkError{Op: "fetching tree of " + repo, Err: err}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, c.statusError(resp)
+	}
+
+	var tree struct {
+		SHA  string `json:"sha"`
+		Tree []struct {
+			Path string `json:"path"`
+			Type string `json:"type"` // blob, tree or commit for a submodule
+			SHA  string `json:"sha"`
+			Size int64  `json:"size"`
+		} `json:"tree"`
+		Truncated bool `json:"truncated"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tree); err != nil {
+		return nil, &NetworkError{Op: "decoding tree of " + repo, Err: err}
+	}
+	if tree.Truncated {
+		c.logger.Warn("Tree truncated by GitHub", "repo", repo, "ref", ref, "entries", len(tree.Tree))
+		return nil, ErrTreeTruncated
+	}
+	c.logger.Info("Fetched tree", "repo", repo, "ref", ref, "sha", tree.SHA, "entries", len(tree.Tree))
+
+	files := []model.FileInfo{}
+	for _, entry := range tree.Tree {
+		if entry.Type != "blob" {
+			continue
+		}
+		rule, limit := threshold(entry.Path)
+		if limit <= 0 || entry.Size <= limit {
+			continue
+		}
+
+		c.logger.Info("Found large file", "path", entry.Path, "size", entry.Size)
+		files = append(files, model.FileInfo{
+			Name:      filepath.FromSlash(entry.Path),
+			Size:      entry.Size,
+			Rule:      rule,
+			Threshold: limit,
+			BlobSHA:   entry.SHA,
+		})
+	}
+
+	return &model.Output{
+		Total: len(files),
+		Files: files,
+	}, nil
+}
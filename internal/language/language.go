@@ -0,0 +1,60 @@
+package language
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Unknown is the language reported for files that match no entry
+const Unknown = "Other"
+
+// languages.json maps file extensions and names to languages, in the spirit of GitHub linguist
+//
+//go:embed languages.json
+var languagesJSON []byte
+
+// Language is an entry of the embedded language table
+type Language struct {
+	Name       string   `json:"name"`
+	Type       string   `json:"type"` // programming, markup, data or prose
+	Extensions []string `json:"extensions"`
+	Filenames  []string `json:"filenames"`
+}
+
+var (
+	byExtension = map[string]*Language{}
+	byFilename  = map[string]*Language{}
+)
+
+func init() {
+	var table struct {
+		Languages []*Language `json:"languages"`
+	}
+	if err := json.Unmarshal(languagesJSON, &table); err != nil {
+		panic(fmt.Sprintf("parsing embedded languages.json: %v", err))
+	}
+	for _, l := range table.Languages {
+		for _, ext := range l.Extensions {
+			byExtension[strings.ToLower(ext)] = l
+		}
+		for _, name := range l.Filenames {
+			byFilename[name] = l
+		}
+	}
+}
+
+// Detect returns the language and its type for a slash separated path. Exact file names
+// take precedence over extensions; unknown files are reported as Unknown with an empty type.
+func Detect(relPath string) (string, string) {
+	base := path.Base(relPath)
+	if l, ok := byFilename[base]; ok {
+		return l.Name, l.Type
+	}
+	if l, ok := byExtension[strings.ToLower(path.Ext(base))]; ok {
+		return l.Name, l.Type
+	}
+	return Unknown, ""
+}
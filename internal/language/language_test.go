@@ -0,0 +1,27 @@
+package language
+
+import "testing"
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		path     string
+		wantName string
+		wantType string
+	}{
+		{"cmd/main.go", "Go", "programming"},
+		{"web/App.TSX", "TypeScript", "programming"},
+		{"build/Dockerfile", "Dockerfile", "programming"},
+		{"Makefile", "Makefile", "programming"},
+		{"docs/README.md", "Markdown", "prose"},
+		{"config/app.yaml", "YAML", "data"},
+		{"assets/logo.png", Unknown, ""},
+		{"LICENSE", "Text", "prose"},
+	}
+
+	for _, tt := range tests {
+		name, kind := Detect(tt.path)
+		if name != tt.wantName || kind != tt.wantType {
+			t.Errorf("Detect(%q) = (%q, %q), want (%q, %q)", tt.path, name, kind, tt.wantName, tt.wantType)
+		}
+	}
+}
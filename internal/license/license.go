@@ -0,0 +1,145 @@
+package license
+
+import (
+	"embed"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/babyfaceeasy/repo-scanner/internal/language"
+)
+
+// NoAssertion is the SPDX identifier reported when a license file matches no known license
+const NoAssertion = "NOASSERTION"
+
+// minConfidence is the similarity a file needs to be identified as a license
+const minConfidence = 0.8
+
+// maxLicenseSize bounds how much of a license file is read
+const maxLicenseSize = 256 * 1024
+
+//go:embed licenses/*.txt
+var licenseFS embed.FS
+
+// template is an embedded license text, named after its SPDX identifier. Full texts are
+// compared with the Dice coefficient; excerpts of long licenses (their opening sections)
+// are compared by how much of the excerpt the file contains.
+type template struct {
+	id      string
+	excerpt bool
+	bigrams map[string]bool
+}
+
+// excerpts lists the licenses embedded as an excerpt rather than in full
+var excerpts = map[string]bool{
+	"AGPL-3.0":   true,
+	"Apache-2.0": true,
+	"GPL-1.0":    true,
+	"GPL-2.0":    true,
+	"GPL-3.0":    true,
+	"LGPL-2.0":   true,
+	"LGPL-2.1":   true,
+	"LGPL-3.0":   true,
+	"MPL-1.1":    true,
+	"MPL-2.0":    true,
+}
+
+var templates []template
+
+func init() {
+	entries, err := licenseFS.ReadDir("licenses")
+	if err != nil {
+		panic(fmt.Sprintf("reading embedded licenses: %v", err))
+	}
+	for _, e := range entries {
+		data, err := licenseFS.ReadFile("licenses/" + e.Name())
+		if err != nil {
+			panic(fmt.Sprintf("reading embedded license %s: %v", e.Name(), err))
+		}
+		id := strings.TrimSuffix(e.Name(), ".txt")
+		templates = append(templates, template{
+			id:      id,
+			excerpt: excerpts[id],
+			bigrams: bigrams(normalize(string(data))),
+		})
+	}
+}
+
+var licenseFileRe = regexp.MustCompile(`(?i)^(?:(?:un)?licen[cs]e|copying)(?:[-._][\w.-]*)?$`)
+
+// IsLicenseFile reports whether a slash separated path looks like a license file,
+// e.g. LICENSE, LICENSE.md, LICENCE-MIT or COPYING.LESSER, but not license.go
+func IsLicenseFile(relPath string) bool {
+	base := path.Base(relPath)
+	if !licenseFileRe.MatchString(base) {
+		return false
+	}
+	_, kind := language.Detect(base)
+	return kind != "programming"
+}
+
+// Identify reads a license text and returns the SPDX identifier of the closest embedded
+// license with its similarity, or NoAssertion when nothing is similar enough
+func Identify(r io.Reader) (string, float64, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxLicenseSize))
+	if err != nil {
+		return "", 0, fmt.Errorf("reading license: %w", err)
+	}
+	text := bigrams(normalize(string(data)))
+
+	bestID, bestScore, bestSize := NoAssertion, 0.0, 0
+	for _, t := range templates {
+		score := similarity(t, text)
+		// prefer the larger template on ties, e.g. BSD-3-Clause over BSD-2-Clause
+		if score > bestScore || (score == bestScore && len(t.bigrams) > bestSize) {
+			bestID, bestScore, bestSize = t.id, score, len(t.bigrams)
+		}
+	}
+
+	if bestScore < minConfidence {
+		return NoAssertion, bestScore, nil
+	}
+	return bestID, bestScore, nil
+}
+
+func similarity(t template, text map[string]bool) float64 {
+	if len(t.bigrams) == 0 || len(text) == 0 {
+		return 0
+	}
+	common := 0
+	for b := range t.bigrams {
+		if text[b] {
+			common++
+		}
+	}
+	if t.excerpt {
+		return float64(common) / float64(len(t.bigrams))
+	}
+	return 2 * float64(common) / float64(len(t.bigrams)+len(text))
+}
+
+var (
+	copyrightLineRe = regexp.MustCompile(`(?im)^\s*(?:copyright|\(c\)|©).*$`)
+	nonWordRe       = regexp.MustCompile(`[^a-z0-9]+`)
+)
+
+// normalize lowercases the text, drops copyright lines (which differ per project) and
+// punctuation, and unifies spelling variants, returning the remaining words
+func normalize(text string) []string {
+	text = copyrightLineRe.ReplaceAllString(text, " ")
+	text = strings.ToLower(text)
+	text = strings.ReplaceAll(text, "&", " and ")
+	text = strings.ReplaceAll(text, "licence", "license")
+	return strings.Fields(nonWordRe.ReplaceAllString(text, " "))
+}
+
+// bigrams returns the set of adjacent word pairs, which keeps some word order information
+func bigrams(words []string) map[string]bool {
+	set := make(map[string]bool, len(words))
+	for i := 0; i+1 < len(words); i++ {
+		set[words[i]+" "+words[i+1]] = true
+	}
+	return set
+}
@@ -0,0 +1,69 @@
+package license
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestIsLicenseFile(t *testing.T) {
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"LICENSE", true},
+		{"vendor/github.com/x/y/LICENSE.md", true},
+		{"LICENCE-MIT", true},
+		{"COPYING.LESSER", true},
+		{"UNLICENSE", true},
+		{"license.txt", true},
+		{"internal/license/license.go", false},
+		{"README.md", false},
+		{"licenses.json", false},
+	}
+
+	for _, tt := range tests {
+		if got := IsLicenseFile(tt.path); got != tt.want {
+			t.Errorf("IsLicenseFile(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestIdentify(t *testing.T) {
+	embedded := func(id string) string {
+		data, err := licenseFS.ReadFile("licenses/" + id + ".txt")
+		if err != nil {
+			t.Fatalf("reading embedded %s: %v", id, err)
+		}
+		return string(data)
+	}
+
+	// reflowed MIT text with a project specific copyright line
+	mit := "Copyright (c) 2025 Example Corp\n\n" + strings.Join(strings.Fields(embedded("MIT")), " ")
+
+	tests := []struct {
+		name   string
+		text   string
+		wantID string
+	}{
+		{"MIT reflowed", mit, "MIT"},
+		{"BSD-2-Clause", embedded("BSD-2-Clause"), "BSD-2-Clause"},
+		{"BSD-3-Clause", embedded("BSD-3-Clause"), "BSD-3-Clause"},
+		{"ISC", embedded("ISC"), "ISC"},
+		{"0BSD", embedded("0BSD"), "0BSD"},
+		{"GPL-3.0 with trailing terms", embedded("GPL-3.0") + "\nTERMS AND CONDITIONS\n0. Definitions.\n", "GPL-3.0"},
+		{"AGPL-3.0", embedded("AGPL-3.0"), "AGPL-3.0"},
+		{"unrelated text", "This project is proprietary. Do not copy, share or redistribute it in any form.", NoAssertion},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, confidence, err := Identify(strings.NewReader(tt.text))
+			if err != nil {
+				t.Fatalf("Identify() error = %v", err)
+			}
+			if id != tt.wantID {
+				t.Errorf("Identify() = %s (%.2f), want %s", id, confidence, tt.wantID)
+			}
+		})
+	}
+}
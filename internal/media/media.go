@@ -0,0 +1,300 @@
+package media
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"  // register the GIF header decoder
+	_ "image/jpeg" // register the JPEG header decoder
+	_ "image/png"  // register the PNG header decoder
+	"io"
+	"math"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/babyfaceeasy/repo-scanner/internal/model"
+)
+
+// Formats reported in model.MediaInfo.Format
+const (
+	FormatPNG  = "png"
+	FormatJPEG = "jpeg"
+	FormatGIF  = "gif"
+	FormatWebP = "webp"
+	FormatMP4  = "mp4"
+	FormatMOV  = "mov"
+)
+
+const (
+	// maxMoovSize bounds the metadata box read from MP4 files
+	maxMoovSize = 16 << 20
+	// maxDimension is the width or height above which an image is considered oversized
+	maxDimension = 4096
+	// maxIconDimension is the largest sensible width or height of an icon
+	maxIconDimension = 512
+	// largeImageSize is the file size above which PNG and GIF files get a format hint
+	largeImageSize = 1 << 20
+	// maxJPEGBytesPerPixel is the density above which a JPEG is saved at needlessly high quality
+	maxJPEGBytesPerPixel = 0.5
+	// maxVideoBitrate is the bitrate in bits per second above which a video gets a hint
+	maxVideoBitrate = 8_000_000
+)
+
+// iconWords mark file names of icons, logos and other small UI images
+var iconWords = []string{"icon", "favicon", "logo", "avatar", "thumb", "sprite", "badge"}
+
+// Probe decodes the header of the image or video at filePath and returns its dimensions,
+// duration and optimisation hints. relPath is used for hints based on the file name.
+// It returns nil when the format is not supported.
+func Probe(filePath, relPath string) (*model.MediaInfo, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	head := make([]byte, 32)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, err
+	}
+	head = head[:n]
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	var m *model.MediaInfo
+	switch {
+	case bytes.HasPrefix(head, []byte("\x89PNG\r\n\x1a\n")):
+		m, err = probeImage(f, FormatPNG)
+	case bytes.HasPrefix(head, []byte("\xff\xd8\xff")):
+		m, err = probeImage(f, FormatJPEG)
+	case bytes.HasPrefix(head, []byte("GIF87a")), bytes.HasPrefix(head, []byte("GIF89a")):
+		m, err = probeImage(f, FormatGIF)
+	case len(head) >= 12 && string(head[:4]) == "RIFF" && string(head[8:12]) == "WEBP":
+		m, err = probeWebP(f)
+	case len(head) >= 12 && string(head[4:8]) == "ftyp":
+		m, err = probeMP4(f, info.Size(), string(head[8:12]))
+	default:
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("decoding %s header: %w", path.Base(relPath), err)
+	}
+
+	m.Hints = hints(m, relPath, info.Size())
+	return m, nil
+}
+
+func probeImage(r io.Reader, format string) (*model.MediaInfo, error) {
+	cfg, _, err := image.DecodeConfig(bufio.NewReader(r))
+	if err != nil {
+		return nil, err
+	}
+	return &model.MediaInfo{Format: format, Width: cfg.Width, Height: cfg.Height}, nil
+}
+
+// probeWebP reads the canvas size from the first chunk of a WebP file
+func probeWebP(r io.Reader) (*model.MediaInfo, error) {
+	buf := make([]byte, 30)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, err
+	}
+
+	m := &model.MediaInfo{Format: FormatWebP}
+	switch string(buf[12:16]) {
+	case "VP8 ":
+		// lossy: frame tag, start code 9d 01 2a, then 14-bit width and height
+		if !bytes.Equal(buf[23:26], []byte{0x9d, 0x01, 0x2a}) {
+			return nil, errors.New("invalid VP8 start code")
+		}
+		m.Width = int(binary.LittleEndian.Uint16(buf[26:28]) & 0x3fff)
+		m.Height = int(binary.LittleEndian.Uint16(buf[28:30]) & 0x3fff)
+	case "VP8L":
+		// lossless: signature 0x2f, then 14-bit width-1 and height-1
+		if buf[20] != 0x2f {
+			return nil, errors.New("invalid VP8L signature")
+		}
+		bits := binary.LittleEndian.Uint32(buf[21:25])
+		m.Width = int(bits&0x3fff) + 1
+		m.Height = int(bits>>14&0x3fff) + 1
+	case "VP8X":
+		// extended: flags, reserved, then 24-bit canvas width-1 and height-1
+		m.Width = int(uint32(buf[24])|uint32(buf[25])<<8|uint32(buf[26])<<16) + 1
+		m.Height = int(uint32(buf[27])|uint32(buf[28])<<8|uint32(buf[29])<<16) + 1
+	default:
+		return nil, fmt.Errorf("unknown WebP chunk %q", buf[12:16])
+	}
+	return m, nil
+}
+
+// probeMP4 reads the duration and video track size from the moov box of an MP4 or QuickTime file
+func probeMP4(r io.ReadSeeker, size int64, brand string) (*model.MediaInfo, error) {
+	m := &model.MediaInfo{Format: FormatMP4}
+	if brand == "qt  " {
+		m.Format = FormatMOV
+	}
+
+	// moov usually follows mdat in unoptimised files, so skip over boxes until it is found
+	var offset int64
+	for offset < size {
+		boxType, start, end, err := readBoxHeader(r, offset, size)
+		if err != nil {
+			return nil, err
+		}
+		if boxType == "moov" {
+			if end-start > maxMoovSize {
+				return nil, fmt.Errorf("moov box of %d bytes is too large", end-start)
+			}
+			moov := make([]byte, end-start)
+			if _, err := io.ReadFull(r, moov); err != nil {
+				return nil, err
+			}
+			parseMoov(moov, m)
+			return m, nil
+		}
+		offset = end
+	}
+	return nil, errors.New("moov box not found")
+}
+
+// readBoxHeader reads the header of the box at offset and returns its type and the bounds of its payload
+func readBoxHeader(r io.ReadSeeker, offset, size int64) (string, int64, int64, error) {
+	if _, err := r.Seek(offset, io.SeekStart); err != nil {
+		return "", 0, 0, err
+	}
+	header := make([]byte, 16)
+	if _, err := io.ReadFull(r, header[:8]); err != nil {
+		return "", 0, 0, err
+	}
+
+	boxSize := int64(binary.BigEndian.Uint32(header[:4]))
+	boxType := string(header[4:8])
+	start := offset + 8
+	switch boxSize {
+	case 0:
+		boxSize = size - offset
+	case 1:
+		if _, err := io.ReadFull(r, header[8:16]); err != nil {
+			return "", 0, 0, err
+		}
+		boxSize = int64(binary.BigEndian.Uint64(header[8:16]))
+		start += 8
+	}
+	if boxSize < start-offset || offset+boxSize > size {
+		return "", 0, 0, fmt.Errorf("invalid %q box size %d", boxType, boxSize)
+	}
+	return boxType, start, offset + boxSize, nil
+}
+
+// parseMoov fills in the duration from mvhd and the size of the first visual track from tkhd
+func parseMoov(moov []byte, m *model.MediaInfo) {
+	eachBox(moov, func(box string, payload []byte) {
+		switch box {
+		case "mvhd":
+			timescale, duration := mvhdDuration(payload)
+			if timescale > 0 {
+				m.Duration = math.Round(float64(duration)/float64(timescale)*100) / 100
+			}
+		case "trak":
+			if m.Width > 0 {
+				return
+			}
+			eachBox(payload, func(child string, tkhd []byte) {
+				if child == "tkhd" {
+					m.Width, m.Height = tkhdSize(tkhd)
+				}
+			})
+		}
+	})
+}
+
+// eachBox calls fn with the type and payload of each box contained in data
+func eachBox(data []byte, fn func(string, []byte)) {
+	for len(data) >= 8 {
+		size := int(binary.BigEndian.Uint32(data[:4]))
+		if size < 8 || size > len(data) {
+			return
+		}
+		fn(string(data[4:8]), data[8:size])
+		data = data[size:]
+	}
+}
+
+func mvhdDuration(p []byte) (uint32, uint64) {
+	if len(p) < 20 {
+		return 0, 0
+	}
+	if p[0] == 1 {
+		if len(p) < 32 {
+			return 0, 0
+		}
+		return binary.BigEndian.Uint32(p[20:24]), binary.BigEndian.Uint64(p[24:32])
+	}
+	return binary.BigEndian.Uint32(p[12:16]), uint64(binary.BigEndian.Uint32(p[16:20]))
+}
+
+func tkhdSize(p []byte) (int, int) {
+	// width and height are 16.16 fixed point values closing the box
+	offset := 76
+	if len(p) > 0 && p[0] == 1 {
+		offset = 88
+	}
+	if len(p) < offset+8 {
+		return 0, 0
+	}
+	return int(binary.BigEndian.Uint32(p[offset:]) >> 16), int(binary.BigEndian.Uint32(p[offset+4:]) >> 16)
+}
+
+// hints suggests how a large asset could be made smaller
+func hints(m *model.MediaInfo, relPath string, size int64) []string {
+	var out []string
+	longest := max(m.Width, m.Height)
+
+	if m.Duration == 0 && longest > maxIconDimension && isIconName(relPath) {
+		out = append(out, fmt.Sprintf("icon stored at %dx%d; resize it to the size it is displayed at", m.Width, m.Height))
+	} else if m.Duration == 0 && longest > maxDimension {
+		out = append(out, fmt.Sprintf("%dx%d is larger than most screens; resize it to the largest size displayed", m.Width, m.Height))
+	}
+
+	switch m.Format {
+	case FormatPNG:
+		if size > largeImageSize {
+			out = append(out, "large PNG; photographs are usually much smaller as JPEG or WebP")
+		}
+	case FormatGIF:
+		if size > largeImageSize {
+			out = append(out, "large GIF; animations are usually much smaller as MP4 or WebP")
+		}
+	case FormatJPEG:
+		if pixels := m.Width * m.Height; pixels > 0 && float64(size)/float64(pixels) > maxJPEGBytesPerPixel {
+			out = append(out, "JPEG quality is very high; re-encoding at quality 80-85 usually saves space")
+		}
+	case FormatMP4, FormatMOV:
+		if m.Duration > 0 {
+			if bitrate := float64(size) * 8 / m.Duration; bitrate > maxVideoBitrate {
+				out = append(out, fmt.Sprintf("bitrate of %.1f Mbps; re-encoding for the web usually needs far less", bitrate/1e6))
+			}
+		}
+	}
+	return out
+}
+
+func isIconName(relPath string) bool {
+	name := strings.ToLower(path.Base(relPath))
+	for _, w := range iconWords {
+		if strings.Contains(name, w) {
+			return true
+		}
+	}
+	return false
+}
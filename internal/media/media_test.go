@@ -0,0 +1,150 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/babyfaceeasy/repo-scanner/internal/model"
+)
+
+func TestProbe(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		name       string
+		file       string
+		data       []byte
+		wantFormat string
+		wantWidth  int
+		wantHeight int
+		wantDur    float64
+		wantHint   string
+	}{
+		{name: "png", file: "diagram.png", data: encode(t, "png", 640, 480), wantFormat: FormatPNG, wantWidth: 640, wantHeight: 480},
+		{name: "oversized icon", file: "assets/app-icon.png", data: encode(t, "png", 1024, 1024), wantFormat: FormatPNG, wantWidth: 1024, wantHeight: 1024, wantHint: "icon stored at 1024x1024"},
+		{name: "oversized image", file: "hero.png", data: encode(t, "png", 5000, 20), wantFormat: FormatPNG, wantWidth: 5000, wantHeight: 20, wantHint: "larger than most screens"},
+		{name: "jpeg", file: "photo.jpg", data: encode(t, "jpeg", 300, 200), wantFormat: FormatJPEG, wantWidth: 300, wantHeight: 200},
+		{name: "gif", file: "spinner.gif", data: encode(t, "gif", 32, 16), wantFormat: FormatGIF, wantWidth: 32, wantHeight: 16},
+		{name: "webp lossy", file: "a.webp", data: webp("VP8 ", []byte{0, 0, 0, 0x9d, 0x01, 0x2a, 0x20, 0x03, 0x58, 0x02}), wantFormat: FormatWebP, wantWidth: 800, wantHeight: 600},
+		{name: "webp lossless", file: "b.webp", data: webp("VP8L", []byte{0x2f, 0x3f, 0xc0, 0x0f, 0x00}), wantFormat: FormatWebP, wantWidth: 64, wantHeight: 64},
+		{name: "webp extended", file: "c.webp", data: webp("VP8X", []byte{0x02, 0, 0, 0, 0x7f, 0x07, 0, 0x37, 0x04, 0}), wantFormat: FormatWebP, wantWidth: 1920, wantHeight: 1080},
+		{name: "mp4 with moov last", file: "intro.mp4", data: mp4(1920, 1080, 1000, 12500), wantFormat: FormatMP4, wantWidth: 1920, wantHeight: 1080, wantDur: 12.5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, filepath.Base(tt.file))
+			if err := os.WriteFile(path, tt.data, 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := Probe(path, tt.file)
+			if err != nil {
+				t.Fatalf("Probe() error = %v", err)
+			}
+			if got == nil {
+				t.Fatal("Probe() = nil, want media info")
+			}
+			if got.Format != tt.wantFormat || got.Width != tt.wantWidth || got.Height != tt.wantHeight || got.Duration != tt.wantDur {
+				t.Errorf("Probe() = %+v, want %s %dx%d %vs", got, tt.wantFormat, tt.wantWidth, tt.wantHeight, tt.wantDur)
+			}
+			hints := strings.Join(got.Hints, "; ")
+			if (tt.wantHint == "") != (hints == "") || !strings.Contains(hints, tt.wantHint) {
+				t.Errorf("Probe().Hints = %q, want %q", hints, tt.wantHint)
+			}
+		})
+	}
+}
+
+func TestProbeUnsupported(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notes.txt")
+	if err := os.WriteFile(path, []byte("just text"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := Probe(path, "notes.txt")
+	if err != nil || got != nil {
+		t.Errorf("Probe() = %+v, %v, want nil, nil", got, err)
+	}
+
+	if err := os.WriteFile(path, []byte("\x89PNG\r\n\x1a\ntruncated"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Probe(path, "broken.png"); err == nil {
+		t.Error("Probe() expected error for a truncated PNG")
+	}
+}
+
+func TestHints(t *testing.T) {
+	got := hints(&model.MediaInfo{Format: FormatMP4, Width: 1280, Height: 720, Duration: 10}, "clip.mp4", 50<<20)
+	if len(got) != 1 || !strings.Contains(got[0], "bitrate of 41.9 Mbps") {
+		t.Errorf("hints() = %q, want a bitrate hint", got)
+	}
+
+	got = hints(&model.MediaInfo{Format: FormatJPEG, Width: 100, Height: 100}, "photo.jpg", 10000)
+	if len(got) != 1 || !strings.Contains(got[0], "quality") {
+		t.Errorf("hints() = %q, want a JPEG quality hint", got)
+	}
+
+	got = hints(&model.MediaInfo{Format: FormatPNG, Width: 2000, Height: 1500}, "photo.png", 3<<20)
+	if len(got) != 1 || !strings.Contains(got[0], "JPEG or WebP") {
+		t.Errorf("hints() = %q, want a format hint", got)
+	}
+}
+
+func encode(t *testing.T, format string, w, h int) []byte {
+	t.Helper()
+	img := image.NewPaletted(image.Rect(0, 0, w, h), color.Palette{color.Black, color.White})
+	var buf bytes.Buffer
+	var err error
+	switch format {
+	case "png":
+		err = png.Encode(&buf, img)
+	case "jpeg":
+		err = jpeg.Encode(&buf, img, nil)
+	case "gif":
+		err = gif.Encode(&buf, img, nil)
+	}
+	if err != nil {
+		t.Fatalf("encoding %s: %v", format, err)
+	}
+	return buf.Bytes()
+}
+
+func webp(chunk string, payload []byte) []byte {
+	data := []byte("RIFF\x00\x00\x00\x00WEBP" + chunk + "\x00\x00\x00\x00")
+	data = append(data, payload...)
+	return append(data, make([]byte, 16)...)
+}
+
+// mp4 builds an ftyp, mdat, moov file with a single video track
+func mp4(width, height, timescale, duration uint32) []byte {
+	mvhd := make([]byte, 100)
+	binary.BigEndian.PutUint32(mvhd[12:], timescale)
+	binary.BigEndian.PutUint32(mvhd[16:], duration)
+
+	tkhd := make([]byte, 84)
+	binary.BigEndian.PutUint32(tkhd[76:], width<<16)
+	binary.BigEndian.PutUint32(tkhd[80:], height<<16)
+
+	var out []byte
+	out = append(out, box("ftyp", []byte("isom\x00\x00\x02\x00isomiso2mp41"))...)
+	out = append(out, box("mdat", make([]byte, 1000))...)
+	out = append(out, box("moov", append(box("mvhd", mvhd), box("trak", box("tkhd", tkhd))...))...)
+	return out
+}
+
+func box(boxType string, payload []byte) []byte {
+	b := make([]byte, 8, 8+len(payload))
+	binary.BigEndian.PutUint32(b, uint32(8+len(payload)))
+	copy(b[4:], boxType)
+	return append(b, payload...)
+}
@@ -3,12 +3,153 @@ package model
 import (
 	"fmt"
 	"strings"
+
+	"github.com/babyfaceeasy/repo-scanner/internal/pathmatch"
+)
+
+// Baseline diff statuses reported in FileInfo.Status
+const (
+	StatusNew    = "new"
+	StatusGrew   = "grew"
+	StatusShrank = "shrank"
+)
+
+// File categories reported in FileInfo.Category
+const (
+	CategoryText       = "text"
+	CategoryImage      = "image"
+	CategoryVideo      = "video"
+	CategoryAudio      = "audio"
+	CategoryArchive    = "archive"
+	CategoryDatabase   = "database"
+	CategoryDocument   = "document"
+	CategoryExecutable = "executable"
+	CategoryBinary     = "binary"
+)
+
+// Categories lists every file category
+var Categories = []string{
+	CategoryText, CategoryImage, CategoryVideo, CategoryAudio, CategoryArchive,
+	CategoryDatabase, CategoryDocument, CategoryExecutable, CategoryBinary,
+}
+
+// Scan modes for Config.Mode
+const (
+	ModeTarball = "tarball" // Download the repository and walk its files
+	ModeTree    = "tree"    // Read file sizes from the Git Trees API without downloading contents
+)
+
+// Rule precedence modes for Config.RulePrecedence
+const (
+	PrecedenceFirstMatch   = "first_match"
+	PrecedenceMostSpecific = "most_specific"
 )
 
 // Config represents the input JSON structure
 type Config struct {
-	CloneURL string  `json:"clone_url"`
-	Size     float64 `json:"size"` // Size threshold in MB
+	CloneURL          string         `json:"clone_url"`
+	Mode              string         `json:"mode,omitempty"`               // tarball (default) or tree
+	Size              float64        `json:"size,omitempty"`               // Size threshold in MB
+	Rules             []SizeRule     `json:"rules,omitempty"`              // Per-path and per-extension thresholds
+	RulePrecedence    string         `json:"rule_precedence,omitempty"`    // first_match (default) or most_specific
+	TopN              int            `json:"top_n,omitempty"`              // Report the N largest files regardless of size
+	Histogram         bool           `json:"histogram,omitempty"`          // Report size percentiles and a histogram
+	DirDepth          int            `json:"dir_depth,omitempty"`          // Aggregate sizes per directory down to this depth
+	TopDirs           int            `json:"top_dirs,omitempty"`           // Number of largest directories to report (default 20)
+	Languages         bool           `json:"languages,omitempty"`          // Report bytes and files per language
+	Licenses          bool           `json:"licenses,omitempty"`           // Identify license files by SPDX ID
+	ExcludeVendored   bool           `json:"exclude_vendored,omitempty"`   // Skip third-party files in threshold checks
+	ExcludeGenerated  bool           `json:"exclude_generated,omitempty"`  // Skip generated files in threshold checks
+	Duplicates        bool           `json:"duplicates,omitempty"`         // Hash large files and group identical ones
+	CompressedSize    bool           `json:"compressed_size,omitempty"`    // Report gzip and zstd sizes of large files
+	Media             bool           `json:"media,omitempty"`              // Report dimensions and duration of large images and videos
+	Archives          bool           `json:"archives,omitempty"`           // Check files inside zip and tar archives
+	ArchiveDepth      int            `json:"archive_depth,omitempty"`      // Levels of nested archives to open (default 3)
+	Submodules        bool           `json:"submodules,omitempty"`         // Download and scan submodules at their pinned commits
+	SubmoduleDepth    int            `json:"submodule_depth,omitempty"`    // Levels of nested submodules to download (default 3)
+	SkipArchived      bool           `json:"skip_archived,omitempty"`      // Report archived repositories as skipped instead of scanning them
+	MaxRepoSize       float64        `json:"max_repo_size,omitempty"`      // Refuse repositories GitHub reports as larger than this, in MB
+	Categories        []string       `json:"categories,omitempty"`         // Only report files in these categories
+	ExcludeCategories []string       `json:"exclude_categories,omitempty"` // Never report files in these categories
+	Secrets           *SecretsConfig `json:"secrets,omitempty"`            // Enables the secrets pass when present
+	Policy            *Policy        `json:"policy,omitempty"`             // Limits evaluated after the scan
+}
+
+// contentOption returns the first option set that the tree mode does not support, or an empty
+// string when the scan can be answered from the paths and sizes of the Git Trees API alone
+func (c *Config) contentOption() string {
+	options := []struct {
+		name string
+		set  bool
+	}{
+		{"top_n", c.TopN > 0},
+		{"histogram", c.Histogram},
+		{"dir_depth", c.DirDepth > 0},
+		{"languages", c.Languages},
+		{"licenses", c.Licenses},
+		{"exclude_vendored", c.ExcludeVendored},
+		{"exclude_generated", c.ExcludeGenerated},
+		{"duplicates", c.Duplicates},
+		{"compressed_size", c.CompressedSize},
+		{"media", c.Media},
+		{"archives", c.Archives},
+		{"submodules", c.Submodules},
+		{"categories", len(c.Categories) > 0},
+		{"exclude_categories", len(c.ExcludeCategories) > 0},
+		{"secrets", c.Secrets != nil},
+	}
+	for _, o := range options {
+		if o.set {
+			return o.name
+		}
+	}
+	return ""
+}
+
+// SizeRule overrides the size threshold for files matching Pattern (see pathmatch.Match)
+type SizeRule struct {
+	Pattern string  `json:"pattern"`
+	Size    float64 `json:"size"` // Size threshold in MB
+}
+
+// SecretsConfig configures the optional secrets pass over every text file
+type SecretsConfig struct {
+	RulesFile         string   `json:"rules_file,omitempty"`         // JSON file with extra rules and allowlists
+	AllowPaths        []string `json:"allow_paths,omitempty"`        // Path patterns that are never searched
+	AllowFingerprints []string `json:"allow_fingerprints,omitempty"` // Fingerprints of accepted findings
+	MaxFileSize       float64  `json:"max_file_size,omitempty"`      // Largest file searched in MB (default 5)
+}
+
+// SecretFinding is a likely secret found in a file. Match is redacted.
+type SecretFinding struct {
+	File        string `json:"file"`
+	Line        int    `json:"line"`
+	RuleID      string `json:"rule_id"`
+	Description string `json:"description"`
+	Match       string `json:"match"`
+	Fingerprint string `json:"fingerprint"` // Stable ID used to allowlist the finding
+}
+
+// Policy defines the limits a scan result must stay within. Unset limits are not checked.
+type Policy struct {
+	MaxCount     *int    `json:"max_count,omitempty"`      // Maximum number of reported files
+	MaxTotalSize float64 `json:"max_total_size,omitempty"` // Maximum combined size of reported files in MB
+	MaxFileSize  float64 `json:"max_file_size,omitempty"`  // Maximum size of a single reported file in MB
+	MaxSecrets   *int    `json:"max_secrets,omitempty"`    // Maximum number of secret findings
+}
+
+// PolicyViolation describes a policy rule that failed
+type PolicyViolation struct {
+	Rule    string `json:"rule"`
+	Limit   int64  `json:"limit"`
+	Actual  int64  `json:"actual"`
+	Message string `json:"message"`
+}
+
+// PolicyResult summarises the policy evaluation of a scan
+type PolicyResult struct {
+	Passed     bool              `json:"passed"`
+	Violations []PolicyViolation `json:"violations,omitempty"`
 }
 
 // Validate validates the Config struct
@@ -19,20 +160,232 @@ func (c *Config) Validate() error {
 	if !strings.HasPrefix(c.CloneURL, "https://github.com/") {
 		return fmt.Errorf("clone_url must be a valid GitHub HTTPS URL")
 	}
-	if c.Size <= 0 {
+	if c.TopN < 0 {
+		return fmt.Errorf("top_n must not be negative")
+	}
+	if c.DirDepth < 0 {
+		return fmt.Errorf("dir_depth must not be negative")
+	}
+	if c.TopDirs < 0 {
+		return fmt.Errorf("top_dirs must not be negative")
+	}
+	if c.ArchiveDepth < 0 {
+		return fmt.Errorf("archive_depth must not be negative")
+	}
+	if c.SubmoduleDepth < 0 {
+		return fmt.Errorf("submodule_depth must not be negative")
+	}
+	if c.MaxRepoSize < 0 {
+		return fmt.Errorf("max_repo_size must not be negative")
+	}
+	// the threshold may be omitted when only asking for the largest files or the distribution
+	if c.Size < 0 || (c.Size == 0 && c.TopN == 0 && !c.Histogram && c.DirDepth == 0 && !c.Languages && !c.Licenses) {
+		return fmt.Errorf("size must be positive")
+	}
+	for i, r := range c.Rules {
+		if err := r.Validate(); err != nil {
+			return fmt.Errorf("rules[%d]: %w", i, err)
+		}
+	}
+	for _, category := range append(append([]string{}, c.Categories...), c.ExcludeCategories...) {
+		if !validCategory(category) {
+			return fmt.Errorf("unknown category %q, must be one of %s", category, strings.Join(Categories, ", "))
+		}
+	}
+	switch c.RulePrecedence {
+	case "", PrecedenceFirstMatch, PrecedenceMostSpecific:
+	default:
+		return fmt.Errorf("rule_precedence must be %q or %q", PrecedenceFirstMatch, PrecedenceMostSpecific)
+	}
+	switch c.Mode {
+	case "", ModeTarball:
+	case ModeTree:
+		if option := c.contentOption(); option != "" {
+			return fmt.Errorf("mode %q only reports the files over their threshold and cannot be combined with %s", ModeTree, option)
+		}
+	default:
+		return fmt.Errorf("mode must be %q or %q", ModeTarball, ModeTree)
+	}
+	if c.Secrets != nil {
+		for _, p := range c.Secrets.AllowPaths {
+			if err := pathmatch.Validate(p); err != nil {
+				return fmt.Errorf("secrets: invalid allow_paths pattern %q: %w", p, err)
+			}
+		}
+		if c.Secrets.MaxFileSize < 0 {
+			return fmt.Errorf("secrets: max_file_size must not be negative")
+		}
+	}
+	if c.Policy != nil {
+		if err := c.Policy.Validate(); err != nil {
+			return fmt.Errorf("policy: %w", err)
+		}
+	}
+	return nil
+}
+
+func validCategory(category string) bool {
+	for _, c := range Categories {
+		if c == category {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate validates the SizeRule struct
+func (r *SizeRule) Validate() error {
+	if r.Pattern == "" {
+		return fmt.Errorf("pattern is required")
+	}
+	if err := pathmatch.Validate(r.Pattern); err != nil {
+		return fmt.Errorf("invalid pattern %q: %w", r.Pattern, err)
+	}
+	if r.Size <= 0 {
 		return fmt.Errorf("size must be positive")
 	}
 	return nil
 }
 
-// FileInfo represents a file exceeding the size threshold
+// Validate validates the Policy struct
+func (p *Policy) Validate() error {
+	if p.MaxCount != nil && *p.MaxCount < 0 {
+		return fmt.Errorf("max_count must not be negative")
+	}
+	if p.MaxTotalSize < 0 {
+		return fmt.Errorf("max_total_size must not be negative")
+	}
+	if p.MaxFileSize < 0 {
+		return fmt.Errorf("max_file_size must not be negative")
+	}
+	if p.MaxSecrets != nil && *p.MaxSecrets < 0 {
+		return fmt.Errorf("max_secrets must not be negative")
+	}
+	return nil
+}
+
+// FileInfo represents a file exceeding the size threshold. Files inside archives
+// are named after the archive and the entry, as in assets.zip!/textures/sky.png.
 type FileInfo struct {
-	Name string `json:"name"`
-	Size int64  `json:"size"` // Size in bytes
+	Name             string     `json:"name"`
+	Size             int64      `json:"size"`                        // Size in bytes
+	Hash             string     `json:"hash,omitempty"`              // SHA-256 of the file contents
+	BlobSHA          string     `json:"blob_sha,omitempty"`          // Git blob SHA, reported by tree mode instead of Hash
+	Status           string     `json:"status,omitempty"`            // Baseline diff status (new, grew, shrank)
+	BaselineSize     int64      `json:"baseline_size,omitempty"`     // Size recorded in the baseline, if any
+	Rule             string     `json:"rule,omitempty"`              // Pattern of the size rule that matched, if any
+	Threshold        int64      `json:"threshold,omitempty"`         // Threshold in bytes the file was checked against
+	MIMEType         string     `json:"mime_type,omitempty"`         // MIME type detected from the content
+	Category         string     `json:"category,omitempty"`          // Broad file category, e.g. image or archive
+	Binary           bool       `json:"binary,omitempty"`            // Whether the content is binary rather than text
+	Vendored         bool       `json:"vendored,omitempty"`          // Lies in a third-party directory such as vendor/
+	Generated        bool       `json:"generated,omitempty"`         // Generated, minified or a lock file
+	Archive          string     `json:"archive,omitempty"`           // Archive the entry was read from, for files inside archives
+	GzipSize         int64      `json:"gzip_size,omitempty"`         // Size in bytes after gzip compression
+	ZstdSize         int64      `json:"zstd_size,omitempty"`         // Size in bytes after zstd compression
+	CompressionRatio float64    `json:"compression_ratio,omitempty"` // Size divided by the smaller compressed size
+	Media            *MediaInfo `json:"media,omitempty"`             // Dimensions and duration of images and videos
+}
+
+// MediaInfo describes an image or video decoded from its header
+type MediaInfo struct {
+	Format   string   `json:"format"` // png, jpeg, gif, webp, mp4 or mov
+	Width    int      `json:"width,omitempty"`
+	Height   int      `json:"height,omitempty"`
+	Duration float64  `json:"duration,omitempty"` // Length of a video in seconds
+	Hints    []string `json:"hints,omitempty"`    // Suggestions for making the file smaller
+}
+
+// IsRegression reports whether the file is new or has grown relative to a baseline
+func (f FileInfo) IsRegression() bool {
+	return f.Status == StatusNew || f.Status == StatusGrew
 }
 
 // Output represents the output JSON structure
 type Output struct {
-	Total int        `json:"total"`
-	Files []FileInfo `json:"files"`
+	Total       int             `json:"total"`
+	Files       []FileInfo      `json:"files"`
+	Repository  *Repository     `json:"repository,omitempty"`  // Metadata GitHub reports for the repository
+	Skipped     string          `json:"skipped,omitempty"`     // Why the repository was not scanned
+	Baseline    string          `json:"baseline,omitempty"`    // Baseline file the result was diffed against
+	Regressions int             `json:"regressions,omitempty"` // Number of new or grown files relative to the baseline
+	Policy      *PolicyResult   `json:"policy,omitempty"`      // Policy evaluation, when a policy or baseline is in use
+	Top         []FileInfo      `json:"top,omitempty"`         // Largest files regardless of the threshold
+	Stats       *Stats          `json:"stats,omitempty"`       // Size distribution of all files
+	Directories []DirInfo       `json:"directories,omitempty"` // Largest directories by cumulative size
+	Duplicates  []DuplicateSet  `json:"duplicates,omitempty"`  // Large files with identical contents
+	Secrets     []SecretFinding `json:"secrets,omitempty"`     // Likely secrets, when the secrets pass is enabled
+	Languages   []LanguageStat  `json:"languages,omitempty"`   // Language breakdown of all files
+	Licenses    []LicenseInfo   `json:"licenses,omitempty"`    // License files found anywhere in the tree
+	Submodules  []Submodule     `json:"submodules,omitempty"`  // Submodules declared in .gitmodules files
+}
+
+// Repository is the metadata GitHub reports for a repository
+type Repository struct {
+	FullName      string `json:"full_name"`
+	DefaultBranch string `json:"default_branch"`
+	SizeKB        int64  `json:"size_kb"`    // Disk usage as reported by GitHub, which is approximate
+	Visibility    string `json:"visibility"` // public, private or internal
+	Archived      bool   `json:"archived"`
+	Fork          bool   `json:"fork"`
+	Access        string `json:"access,omitempty"` // Highest permission of the token: admin, maintain, push, triage or pull
+}
+
+// Submodule describes a git submodule. Files of scanned submodules are reported under Path.
+type Submodule struct {
+	Name    string `json:"name"`
+	Path    string `json:"path"`              // Path relative to the repository root
+	URL     string `json:"url"`               // URL as declared in .gitmodules
+	Commit  string `json:"commit,omitempty"`  // Pinned commit, resolved when downloading
+	Scanned bool   `json:"scanned"`           // Whether the contents were downloaded and scanned
+	Skipped string `json:"skipped,omitempty"` // Why a submodule was not scanned, when downloading was requested
+}
+
+// LicenseInfo identifies a license file by its closest SPDX license
+type LicenseInfo struct {
+	Path       string  `json:"path"`
+	SPDXID     string  `json:"spdx_id"`    // NOASSERTION when no known license is similar enough
+	Confidence float64 `json:"confidence"` // Text similarity with the matched license, from 0 to 1
+}
+
+// LanguageStat holds the files and bytes of one language
+type LanguageStat struct {
+	Language string  `json:"language"`
+	Type     string  `json:"type,omitempty"` // programming, markup, data or prose
+	Files    int64   `json:"files"`
+	Bytes    int64   `json:"bytes"`
+	Percent  float64 `json:"percent"` // Share of the repository's bytes
+}
+
+// DuplicateSet groups large files that share the same content
+type DuplicateSet struct {
+	Hash        string   `json:"hash"`
+	Size        int64    `json:"size"`         // Size of each copy in bytes
+	Files       []string `json:"files"`        // Paths of every copy
+	WastedBytes int64    `json:"wasted_bytes"` // Bytes that would be saved by keeping a single copy
+}
+
+// DirInfo holds the cumulative size of every file below a directory
+type DirInfo struct {
+	Path  string `json:"path"`
+	Size  int64  `json:"size"`  // Size in bytes
+	Files int64  `json:"files"` // Number of files
+}
+
+// Stats describes the size distribution of every file in the repository.
+// Percentiles are estimated from the histogram and are accurate to within 12.5%.
+type Stats struct {
+	FileCount  int64             `json:"file_count"`
+	TotalBytes int64             `json:"total_bytes"`
+	P50        int64             `json:"p50"`
+	P90        int64             `json:"p90"`
+	P99        int64             `json:"p99"`
+	Histogram  []HistogramBucket `json:"histogram"`
+}
+
+// HistogramBucket counts the files whose size is in [Min, Max)
+type HistogramBucket struct {
+	Min   int64 `json:"min"`
+	Max   int64 `json:"max"`
+	Count int64 `json:"count"`
 }
@@ -0,0 +1,82 @@
+package pathmatch
+
+import (
+	"path"
+	"strings"
+)
+
+// Match reports whether relPath (slash separated) matches pattern. A pattern starting
+// with a dot (".png") matches the extension, a pattern without a slash ("*.min.js")
+// matches the file name, and any other pattern matches the full path, where a "**"
+// segment matches any number of directories.
+func Match(pattern, relPath string) bool {
+	switch {
+	case strings.HasPrefix(pattern, ".") && !strings.ContainsAny(pattern, "/*?["):
+		return strings.EqualFold(path.Ext(relPath), pattern)
+	case !strings.Contains(pattern, "/"):
+		ok, _ := path.Match(pattern, path.Base(relPath))
+		return ok
+	default:
+		return matchSegments(strings.Split(pattern, "/"), strings.Split(relPath, "/"))
+	}
+}
+
+// MatchAny reports whether relPath matches any of the patterns
+func MatchAny(patterns []string, relPath string) bool {
+	for _, p := range patterns {
+		if Match(p, relPath) {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks that every segment of pattern is a well-formed path.Match pattern
+func Validate(pattern string) error {
+	for _, segment := range strings.Split(pattern, "/") {
+		if _, err := path.Match(segment, ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Specificity scores a pattern by its number of literal characters
+func Specificity(pattern string) int {
+	score := 0
+	inClass := false
+	for _, c := range pattern {
+		switch {
+		case c == '[':
+			inClass = true
+		case c == ']':
+			inClass = false
+		case inClass, c == '*', c == '?':
+		default:
+			score++
+		}
+	}
+	return score
+}
+
+// matchSegments matches path segments against pattern segments, where "**" matches any number of segments
+func matchSegments(pattern, segments []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(segments); i++ {
+				if matchSegments(pattern[1:], segments[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(segments) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], segments[0]); !ok {
+			return false
+		}
+		pattern, segments = pattern[1:], segments[1:]
+	}
+	return len(segments) == 0
+}
@@ -0,0 +1,31 @@
+package pathmatch
+
+import "testing"
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		{".png", "a/b/logo.PNG", true},
+		{".png", "logo.png.txt", false},
+		{"*.min.js", "web/dist/app.min.js", true},
+		{"assets/**", "assets/a/b/c.bin", true},
+		{"assets/**", "src/assets/c.bin", false},
+		{"**/testdata/*", "pkg/x/testdata/big.json", true},
+		{"docs/*.pdf", "docs/sub/manual.pdf", false},
+	}
+
+	for _, tt := range tests {
+		if got := Match(tt.pattern, tt.path); got != tt.want {
+			t.Errorf("Match(%q, %q) = %v, want %v", tt.pattern, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestSpecificity(t *testing.T) {
+	if a, b := Specificity("assets/**/*.png"), Specificity("*.png"); a <= b {
+		t.Errorf("Specificity(assets/**/*.png) = %d, want more than Specificity(*.png) = %d", a, b)
+	}
+}
@@ -0,0 +1,131 @@
+package policy
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/babyfaceeasy/repo-scanner/internal/model"
+)
+
+// Rule names reported in model.PolicyViolation
+const (
+	RuleMaxCount            = "max_count"
+	RuleMaxTotalSize        = "max_total_size"
+	RuleMaxFileSize         = "max_file_size"
+	RuleMaxSecrets          = "max_secrets"
+	RuleBaselineRegressions = "baseline_regressions"
+)
+
+// ViolationError is returned when a scan result breaks one or more policy rules
+type ViolationError struct {
+	Violations []model.PolicyViolation
+}
+
+func (e *ViolationError) Error() string {
+	rules := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		rules = append(rules, v.Rule)
+	}
+	return fmt.Sprintf("policy violated: %s", strings.Join(rules, ", "))
+}
+
+// Evaluate checks the scan result against the policy. When the result was diffed
+// against a baseline only regressions (new or grown files) count towards the limits,
+// and any regression is itself a violation. A nil policy only applies the baseline rule.
+func Evaluate(p *model.Policy, result *model.Output) *model.PolicyResult {
+	files := result.Files
+	if result.Baseline != "" {
+		files = regressions(result.Files)
+	}
+
+	var violations []model.PolicyViolation
+	if result.Baseline != "" && len(files) > 0 {
+		violations = append(violations, model.PolicyViolation{
+			Rule:    RuleBaselineRegressions,
+			Limit:   0,
+			Actual:  int64(len(files)),
+			Message: fmt.Sprintf("%d file(s) are new or larger than in the baseline", len(files)),
+		})
+	}
+
+	if p != nil {
+		violations = append(violations, checkLimits(p, files)...)
+		if p.MaxSecrets != nil && len(result.Secrets) > *p.MaxSecrets {
+			violations = append(violations, model.PolicyViolation{
+				Rule:    RuleMaxSecrets,
+				Limit:   int64(*p.MaxSecrets),
+				Actual:  int64(len(result.Secrets)),
+				Message: fmt.Sprintf("found %d likely secret(s), at most %d allowed", len(result.Secrets), *p.MaxSecrets),
+			})
+		}
+	}
+
+	return &model.PolicyResult{
+		Passed:     len(violations) == 0,
+		Violations: violations,
+	}
+}
+
+// checkLimits applies the count and size limits of the policy to files
+func checkLimits(p *model.Policy, files []model.FileInfo) []model.PolicyViolation {
+	var violations []model.PolicyViolation
+
+	if p.MaxCount != nil && len(files) > *p.MaxCount {
+		violations = append(violations, model.PolicyViolation{
+			Rule:    RuleMaxCount,
+			Limit:   int64(*p.MaxCount),
+			Actual:  int64(len(files)),
+			Message: fmt.Sprintf("found %d large file(s), at most %d allowed", len(files), *p.MaxCount),
+		})
+	}
+
+	var total, largest int64
+	var largestName string
+	for _, f := range files {
+		total += f.Size
+		if f.Size > largest {
+			largest = f.Size
+			largestName = f.Name
+		}
+	}
+
+	if p.MaxTotalSize > 0 {
+		limit := mbToBytes(p.MaxTotalSize)
+		if total > limit {
+			violations = append(violations, model.PolicyViolation{
+				Rule:    RuleMaxTotalSize,
+				Limit:   limit,
+				Actual:  total,
+				Message: fmt.Sprintf("large files total %d bytes, at most %d allowed", total, limit),
+			})
+		}
+	}
+
+	if p.MaxFileSize > 0 {
+		limit := mbToBytes(p.MaxFileSize)
+		if largest > limit {
+			violations = append(violations, model.PolicyViolation{
+				Rule:    RuleMaxFileSize,
+				Limit:   limit,
+				Actual:  largest,
+				Message: fmt.Sprintf("%s is %d bytes, at most %d allowed", largestName, largest, limit),
+			})
+		}
+	}
+
+	return violations
+}
+
+func regressions(files []model.FileInfo) []model.FileInfo {
+	var out []model.FileInfo
+	for _, f := range files {
+		if f.IsRegression() {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+func mbToBytes(mb float64) int64 {
+	return int64(mb * 1024 * 1024)
+}
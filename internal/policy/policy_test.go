@@ -0,0 +1,93 @@
+package policy
+
+import (
+	"testing"
+
+	"github.com/babyfaceeasy/repo-scanner/internal/model"
+)
+
+func TestEvaluate(t *testing.T) {
+	result := &model.Output{
+		Total: 2,
+		Files: []model.FileInfo{
+			{Name: "a.bin", Size: 3 * 1024 * 1024},
+			{Name: "b.bin", Size: 1 * 1024 * 1024},
+		},
+	}
+	intPtr := func(i int) *int { return &i }
+
+	tests := []struct {
+		name      string
+		policy    *model.Policy
+		wantRules []string
+	}{
+		{
+			name:   "nil policy passes",
+			policy: nil,
+		},
+		{
+			name:   "within limits",
+			policy: &model.Policy{MaxCount: intPtr(2), MaxTotalSize: 4, MaxFileSize: 3},
+		},
+		{
+			name:      "zero max_count fails on any file",
+			policy:    &model.Policy{MaxCount: intPtr(0)},
+			wantRules: []string{RuleMaxCount},
+		},
+		{
+			name:      "all limits exceeded",
+			policy:    &model.Policy{MaxCount: intPtr(1), MaxTotalSize: 3.5, MaxFileSize: 2},
+			wantRules: []string{RuleMaxCount, RuleMaxTotalSize, RuleMaxFileSize},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Evaluate(tt.policy, result)
+			if got.Passed != (len(tt.wantRules) == 0) {
+				t.Errorf("Evaluate() passed = %v, violations = %v", got.Passed, got.Violations)
+			}
+			if len(got.Violations) != len(tt.wantRules) {
+				t.Fatalf("Evaluate() violations = %v, want rules %v", got.Violations, tt.wantRules)
+			}
+			for i, v := range got.Violations {
+				if v.Rule != tt.wantRules[i] {
+					t.Errorf("violation %d rule = %s, want %s", i, v.Rule, tt.wantRules[i])
+				}
+			}
+		})
+	}
+}
+
+func TestEvaluateSecrets(t *testing.T) {
+	zero := 0
+	result := &model.Output{
+		Secrets: []model.SecretFinding{{File: "config.go", Line: 3, RuleID: "github-token"}},
+	}
+
+	got := Evaluate(&model.Policy{MaxSecrets: &zero}, result)
+	if got.Passed || len(got.Violations) != 1 || got.Violations[0].Rule != RuleMaxSecrets {
+		t.Errorf("Evaluate() violations = %v, want %s", got.Violations, RuleMaxSecrets)
+	}
+}
+
+func TestEvaluateBaseline(t *testing.T) {
+	result := &model.Output{
+		Baseline: "baseline.json",
+		Files: []model.FileInfo{
+			{Name: "shrunk.bin", Size: 5 * 1024 * 1024, Status: model.StatusShrank},
+		},
+	}
+
+	// shrunk files are not regressions and do not count towards limits
+	got := Evaluate(&model.Policy{MaxFileSize: 1}, result)
+	if !got.Passed {
+		t.Errorf("Evaluate() violations = %v, want none", got.Violations)
+	}
+
+	result.Files = append(result.Files, model.FileInfo{Name: "new.bin", Size: 100, Status: model.StatusNew})
+	got = Evaluate(nil, result)
+	if got.Passed || len(got.Violations) != 1 || got.Violations[0].Rule != RuleBaselineRegressions {
+		t.Errorf("Evaluate() violations = %v, want %s", got.Violations, RuleBaselineRegressions)
+	}
+}
@@ -0,0 +1,254 @@
+package progress
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/babyfaceeasy/repo-scanner/pkg/logger"
+	"github.com/mattn/go-isatty"
+)
+
+// refreshInterval is how often a Tracker passes its counters to the Reporter
+const refreshInterval = 200 * time.Millisecond
+
+// DefaultLogInterval is how often a LogReporter logs while a download is running
+const DefaultLogInterval = 5 * time.Second
+
+// Stats is a snapshot of a download and extraction
+type Stats struct {
+	Received int64         // Compressed bytes received, including those of an earlier attempt that was resumed
+	Total    int64         // Size of the compressed tarball, or -1 when the server did not say
+	Files    int64         // Files extracted so far
+	Elapsed  time.Duration // Time since the download started
+	resumed  int64
+}
+
+// Throughput returns the bytes received per second, not counting those of a resumed attempt
+func (s Stats) Throughput() float64 {
+	if s.Elapsed <= 0 {
+		return 0
+	}
+	return float64(s.Received-s.resumed) / s.Elapsed.Seconds()
+}
+
+// Reporter presents progress to the user
+type Reporter interface {
+	Report(s Stats) // called regularly while the download runs
+	Done(s Stats)   // called once when it has finished, successfully or not
+}
+
+// New returns a progress bar on stderr when it is a terminal, and a LogReporter otherwise
+func New(log logger.Logger) Reporter {
+	if isatty.IsTerminal(os.Stderr.Fd()) || isatty.IsCygwinTerminal(os.Stderr.Fd()) {
+		return NewBar(os.Stderr)
+	}
+	return NewLogReporter(log, DefaultLogInterval)
+}
+
+// Tracker counts the progress of one download and passes it to a Reporter.
+// A nil *Tracker is valid and counts nothing, so callers need not check whether progress is on.
+type Tracker struct {
+	reporter Reporter
+	start    time.Time
+	received atomic.Int64
+	total    atomic.Int64
+	files    atomic.Int64
+	resumed  atomic.Int64
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+	once sync.Once
+}
+
+// NewTracker starts tracking a download, reporting to r until Stop is called
+func NewTracker(r Reporter) *Tracker {
+	t := &Tracker{reporter: r, start: time.Now(), stop: make(chan struct{})}
+	t.total.Store(-1)
+
+	t.wg.Add(1)
+	go func() {
+		defer t.wg.Done()
+		ticker := time.NewTicker(refreshInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-t.stop:
+				return
+			case <-ticker.C:
+				t.reporter.Report(t.Stats())
+			}
+		}
+	}()
+	return t
+}
+
+// Stats returns the current counters
+func (t *Tracker) Stats() Stats {
+	if t == nil {
+		return Stats{Total: -1}
+	}
+	return Stats{
+		Received: t.received.Load(),
+		Total:    t.total.Load(),
+		Files:    t.files.Load(),
+		Elapsed:  time.Since(t.start),
+		resumed:  t.resumed.Load(),
+	}
+}
+
+// Start records that the download begins at offset bytes, resuming an earlier attempt when
+// offset is not zero, and that the whole tarball has total bytes, or -1 when unknown
+func (t *Tracker) Start(offset, total int64) {
+	if t == nil {
+		return
+	}
+	t.received.Store(offset)
+	t.resumed.Store(offset)
+	t.total.Store(total)
+}
+
+// Reader returns r counting the bytes read from it as received
+func (t *Tracker) Reader(r io.Reader) io.Reader {
+	if t == nil {
+		return r
+	}
+	return &countingReader{r: r, t: t}
+}
+
+// FileExtracted counts one extracted file
+func (t *Tracker) FileExtracted() {
+	if t == nil {
+		return
+	}
+	t.files.Add(1)
+}
+
+// Stop ends tracking and gives the Reporter the final counters
+func (t *Tracker) Stop() {
+	if t == nil {
+		return
+	}
+	t.once.Do(func() {
+		close(t.stop)
+		t.wg.Wait()
+		t.reporter.Done(t.Stats())
+	})
+}
+
+type countingReader struct {
+	r io.Reader
+	t *Tracker
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.t.received.Add(int64(n))
+	return n, err
+}
+
+// Bar draws a single-line progress bar, redrawn in place
+type Bar struct {
+	w     io.Writer
+	width int
+	mu    sync.Mutex
+	last  int // length of the last line drawn, to blank out leftovers
+}
+
+// NewBar creates a Bar writing to w, which should be a terminal
+func NewBar(w io.Writer) *Bar {
+	return &Bar{w: w, width: 30}
+}
+
+// Report implements Reporter
+func (b *Bar) Report(s Stats) {
+	b.draw(s)
+}
+
+// Done implements Reporter, leaving the final state on its own line
+func (b *Bar) Done(s Stats) {
+	b.draw(s)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	fmt.Fprintln(b.w)
+}
+
+func (b *Bar) draw(s Stats) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var line strings.Builder
+	if s.Total > 0 {
+		frac := min(float64(s.Received)/float64(s.Total), 1)
+		filled := int(frac * float64(b.width))
+		line.WriteString("[" + strings.Repeat("=", filled) + strings.Repeat(" ", b.width-filled) + "]")
+		fmt.Fprintf(&line, " %3.0f%% %s / %s", frac*100, FormatBytes(s.Received), FormatBytes(s.Total))
+	} else {
+		line.WriteString(FormatBytes(s.Received))
+	}
+	fmt.Fprintf(&line, "  %s/s  %d files", FormatBytes(int64(s.Throughput())), s.Files)
+
+	text := line.String()
+	pad := max(b.last-len(text), 0)
+	b.last = len(text)
+	fmt.Fprint(b.w, "\r"+text+strings.Repeat(" ", pad))
+}
+
+// LogReporter logs progress as structured events, at most once per interval.
+// One LogReporter can serve several downloads in a row, such as those of submodules.
+type LogReporter struct {
+	logger   logger.Logger
+	interval time.Duration
+	mu       sync.Mutex
+	next     time.Duration
+}
+
+// NewLogReporter creates a LogReporter that logs every interval
+func NewLogReporter(logger logger.Logger, interval time.Duration) *LogReporter {
+	return &LogReporter{logger: logger, interval: interval, next: interval}
+}
+
+// Report implements Reporter
+func (l *LogReporter) Report(s Stats) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if s.Elapsed < l.next {
+		return
+	}
+	l.next = s.Elapsed + l.interval
+	l.log("Download progress", s)
+}
+
+// Done implements Reporter, getting ready for the next download
+func (l *LogReporter) Done(s Stats) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.next = l.interval
+	l.log("Download finished", s)
+}
+
+func (l *LogReporter) log(msg string, s Stats) {
+	fields := []interface{}{"received_bytes", s.Received, "files_extracted", s.Files, "bytes_per_sec", int64(s.Throughput()), "elapsed_ms", s.Elapsed.Milliseconds()}
+	if s.Total >= 0 {
+		fields = append(fields, "total_bytes", s.Total)
+	}
+	l.logger.Info(msg, fields...)
+}
+
+// FormatBytes formats n with a binary unit, e.g. 1.5 MB
+func FormatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
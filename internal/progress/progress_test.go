@@ -0,0 +1,132 @@
+package progress
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type mockLogger struct {
+	logs []string
+}
+
+func (m *mockLogger) Info(msg string, fields ...interface{})  { m.logs = append(m.logs, msg) }
+func (m *mockLogger) Error(msg string, fields ...interface{}) { m.logs = append(m.logs, msg) }
+func (m *mockLogger) Warn(msg string, fields ...interface{})  { m.logs = append(m.logs, msg) }
+func (m *mockLogger) Debug(msg string, fields ...interface{}) { m.logs = append(m.logs, msg) }
+func (m *mockLogger) Fatal(msg string, fields ...interface{}) { m.logs = append(m.logs, msg) }
+
+type recordingReporter struct {
+	mu      sync.Mutex
+	reports int
+	done    []Stats
+}
+
+func (r *recordingReporter) Report(s Stats) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.reports++
+}
+
+func (r *recordingReporter) Done(s Stats) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.done = append(r.done, s)
+}
+
+func TestTracker(t *testing.T) {
+	rec := &recordingReporter{}
+	tracker := NewTracker(rec)
+
+	tracker.Start(100, 1100)
+	n, err := io.Copy(io.Discard, tracker.Reader(strings.NewReader(strings.Repeat("x", 1000))))
+	if err != nil || n != 1000 {
+		t.Fatalf("copy = %d, %v", n, err)
+	}
+	tracker.FileExtracted()
+	tracker.FileExtracted()
+	time.Sleep(2 * refreshInterval)
+	tracker.Stop()
+	tracker.Stop()
+
+	if rec.reports == 0 {
+		t.Error("Report() never called while tracking")
+	}
+	if len(rec.done) != 1 {
+		t.Fatalf("Done() called %d times, want 1", len(rec.done))
+	}
+	s := rec.done[0]
+	if s.Received != 1100 || s.Total != 1100 || s.Files != 2 {
+		t.Errorf("final stats = %+v, want 1100 of 1100 bytes and 2 files", s)
+	}
+	// the 100 bytes of the resumed attempt do not count towards throughput
+	if want := 1000 / s.Elapsed.Seconds(); s.Throughput() != want {
+		t.Errorf("Throughput() = %v, want %v", s.Throughput(), want)
+	}
+
+	// a nil tracker is a no-op
+	var none *Tracker
+	none.Start(0, 10)
+	none.FileExtracted()
+	none.Stop()
+	if r := none.Reader(strings.NewReader("a")); r == nil {
+		t.Error("nil Tracker returned a nil reader")
+	}
+}
+
+func TestBar(t *testing.T) {
+	var buf bytes.Buffer
+	bar := NewBar(&buf)
+
+	bar.Report(Stats{Received: 512 * 1024, Total: 1024 * 1024, Files: 3, Elapsed: time.Second})
+	line := buf.String()
+	for _, want := range []string{"\r[", "===============               ]", " 50%", "512.0 KB / 1.0 MB", "512.0 KB/s", "3 files"} {
+		if !strings.Contains(line, want) {
+			t.Errorf("bar %q does not contain %q", line, want)
+		}
+	}
+
+	buf.Reset()
+	bar.Done(Stats{Received: 2048, Total: -1, Files: 10, Elapsed: time.Second})
+	out := buf.String()
+	if !strings.HasPrefix(out, "\r2.0 KB  2.0 KB/s  10 files") || !strings.HasSuffix(out, "\n") {
+		t.Errorf("final bar = %q, want the size without a total, ending the line", out)
+	}
+	// the shorter line blanks out what is left of the longer one
+	if len(strings.TrimSuffix(out, "\n")) != len(line) {
+		t.Errorf("final bar is %d characters, want it padded to %d", len(out)-1, len(line))
+	}
+}
+
+func TestLogReporter(t *testing.T) {
+	mockLog := &mockLogger{}
+	reporter := NewLogReporter(mockLog, 5*time.Second)
+
+	for _, elapsed := range []time.Duration{time.Second, 4 * time.Second, 5 * time.Second, 6 * time.Second, 11 * time.Second} {
+		reporter.Report(Stats{Received: 10, Total: -1, Elapsed: elapsed})
+	}
+	reporter.Done(Stats{Received: 10, Total: 10, Elapsed: 12 * time.Second})
+
+	// the next download counts its elapsed time from zero again
+	for _, elapsed := range []time.Duration{time.Second, 5 * time.Second, 6 * time.Second} {
+		reporter.Report(Stats{Received: 10, Total: -1, Elapsed: elapsed})
+	}
+	reporter.Done(Stats{Received: 10, Total: 10, Elapsed: 7 * time.Second})
+
+	want := []string{"Download progress", "Download progress", "Download finished", "Download progress", "Download finished"}
+	if strings.Join(mockLog.logs, ",") != strings.Join(want, ",") {
+		t.Errorf("logs = %v, want %v", mockLog.logs, want)
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	tests := map[int64]string{0: "0 B", 1023: "1023 B", 1024: "1.0 KB", 1536: "1.5 KB", 5 << 20: "5.0 MB", 3 << 30: "3.0 GB"}
+	for n, want := range tests {
+		if got := FormatBytes(n); got != want {
+			t.Errorf("FormatBytes(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
@@ -0,0 +1,226 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/babyfaceeasy/repo-scanner/internal/github"
+	"github.com/babyfaceeasy/repo-scanner/internal/model"
+	"github.com/babyfaceeasy/repo-scanner/pkg/logger"
+)
+
+// ErrCircuitOpen is returned without calling GitHub while the circuit breaker is open
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// State is the state of a CircuitBreaker
+type State int
+
+// Circuit breaker states
+const (
+	StateClosed   State = iota // requests pass through and outcomes are recorded
+	StateOpen                  // requests fail fast until the cool-down has passed
+	StateHalfOpen              // a single trial request decides whether to close or reopen
+)
+
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// CircuitBreaker decorates a GitHubClient so that once GitHub keeps failing, further calls fail
+// fast with ErrCircuitOpen instead of each burning through its retries. Wrap it in a Retrier to
+// retry individual calls while the circuit is closed.
+type CircuitBreaker struct {
+	client       github.GitHubClient
+	logger       logger.Logger
+	failureRatio float64
+	window       int
+	coolDown     time.Duration
+	now          func() time.Time
+
+	mu       sync.Mutex
+	state    State
+	outcomes []bool // ring buffer of the last window calls, true for a failure
+	next     int
+	count    int
+	failures int
+	openedAt time.Time
+	trial    bool // whether the half-open trial call is in flight
+	period   int  // incremented on every transition, so late outcomes can be told apart
+}
+
+// NewCircuitBreaker creates a CircuitBreaker that opens when at least failureRatio of the
+// last window calls failed, and lets a trial call through after coolDown
+func NewCircuitBreaker(client github.GitHubClient, logger logger.Logger, failureRatio float64, window int, coolDown time.Duration) *CircuitBreaker {
+	window = max(window, 1)
+	return &CircuitBreaker{
+		client:       client,
+		logger:       logger,
+		failureRatio: failureRatio,
+		window:       window,
+		coolDown:     coolDown,
+		now:          time.Now,
+		outcomes:     make([]bool, window),
+	}
+}
+
+// DownloadRepo implements GitHubClient, failing fast while the circuit is open
+func (cb *CircuitBreaker) DownloadRepo(ctx context.Context, cloneURL, destDir string) error {
+	return cb.call(func() error {
+		return cb.client.DownloadRepo(ctx, cloneURL, destDir)
+	})
+}
+
+// SubmoduleCommit implements GitHubClient, failing fast while the circuit is open
+func (cb *CircuitBreaker) SubmoduleCommit(ctx context.Context, cloneURL, path string) (string, error) {
+	var commit string
+	err := cb.call(func() error {
+		var err error
+		commit, err = cb.client.SubmoduleCommit(ctx, cloneURL, path)
+		return err
+	})
+	return commit, err
+}
+
+// Repository implements GitHubClient, failing fast while the circuit is open
+func (cb *CircuitBreaker) Repository(ctx context.Context, cloneURL string) (*model.Repository, error) {
+	var info *model.Repository
+	err := cb.call(func() error {
+		var err error
+		info, err = cb.client.Repository(ctx, cloneURL)
+		return err
+	})
+	return info, err
+}
+
+// ScanTree implements GitHubClient, failing fast while the circuit is open
+func (cb *CircuitBreaker) ScanTree(ctx context.Context, repo, ref string, threshold github.ThresholdFunc) (*model.Output, error) {
+	var out *model.Output
+	err := cb.call(func() error {
+		var err error
+		out, err = cb.client.ScanTree(ctx, repo, ref, threshold)
+		return err
+	})
+	return out, err
+}
+
+// DiscardDownload implements GitHubClient. It only removes local state, so it passes
+// through even while the circuit is open.
+func (cb *CircuitBreaker) DiscardDownload(cloneURL string) {
+	cb.client.DiscardDownload(cloneURL)
+}
+
+// State returns the current state, moving from open to half-open once the cool-down has passed
+func (cb *CircuitBreaker) State() State {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	if cb.state == StateOpen && cb.now().Sub(cb.openedAt) >= cb.coolDown {
+		cb.setState(StateHalfOpen)
+	}
+	return cb.state
+}
+
+func (cb *CircuitBreaker) call(fn func() error) error {
+	period, err := cb.before()
+	if err != nil {
+		return err
+	}
+	err = fn()
+	cb.after(period, isFailure(err))
+	return err
+}
+
+// before lets a call through or fails it fast, returning the period the call started in
+func (cb *CircuitBreaker) before() (int, error) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if cb.state == StateOpen && cb.now().Sub(cb.openedAt) >= cb.coolDown {
+		cb.setState(StateHalfOpen)
+	}
+	switch cb.state {
+	case StateOpen:
+		return 0, ErrCircuitOpen
+	case StateHalfOpen:
+		if cb.trial {
+			return 0, ErrCircuitOpen
+		}
+		cb.trial = true
+	}
+	return cb.period, nil
+}
+
+// after records the outcome of a call that started in period. Outcomes of calls that started
+// before the last transition are ignored: a call let through while closed must not decide a
+// half-open circuit, and only the trial is let through while half-open.
+func (cb *CircuitBreaker) after(period int, failed bool) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if period != cb.period {
+		return
+	}
+	if cb.state == StateHalfOpen {
+		cb.trial = false
+		if failed {
+			cb.setState(StateOpen)
+		} else {
+			cb.setState(StateClosed)
+		}
+		return
+	}
+
+	// replace the oldest outcome once the window is full
+	if cb.count == cb.window {
+		if cb.outcomes[cb.next] {
+			cb.failures--
+		}
+	} else {
+		cb.count++
+	}
+	cb.outcomes[cb.next] = failed
+	if failed {
+		cb.failures++
+	}
+	cb.next = (cb.next + 1) % cb.window
+
+	if cb.count == cb.window && float64(cb.failures)/float64(cb.window) >= cb.failureRatio {
+		cb.setState(StateOpen)
+	}
+}
+
+// setState records a transition, clearing the outcomes whenever the circuit closes or opens
+func (cb *CircuitBreaker) setState(state State) {
+	if cb.state == state {
+		return
+	}
+	cb.logger.Warn("Circuit breaker state changed", "from", cb.state.String(), "to", state.String(), "failures", cb.failures, "window", cb.window)
+	cb.state = state
+	cb.period++
+	if state == StateOpen {
+		cb.openedAt = cb.now()
+	}
+	if state != StateHalfOpen {
+		cb.outcomes = make([]bool, cb.window)
+		cb.next, cb.count, cb.failures = 0, 0, 0
+	}
+}
+
+// isFailure reports whether err suggests GitHub is unavailable. Rate limits are handled by
+// waiting and permanent errors such as a missing repository say nothing about GitHub's health.
+func isFailure(err error) bool {
+	var raErr *github.RetryAfterError
+	if err == nil || errors.As(err, &raErr) {
+		return false
+	}
+	return isRetryable(err)
+}
@@ -0,0 +1,110 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/babyfaceeasy/repo-scanner/internal/github"
+)
+
+// Config describes how an operation is retried
+type Config struct {
+	Policy         Policy           // Wait between attempts; nil uses DefaultPolicy
+	MaxAttempts    int              // Attempts including the first; 0 means no limit other than MaxElapsed and the context
+	MaxElapsed     time.Duration    // Stop retrying once the next attempt would start later than this after the first; 0 means no limit
+	AttemptTimeout time.Duration    // Deadline for the context of each attempt; 0 means none
+	Retryable      func(error) bool // Whether an error is worth another attempt; nil uses the classification in this package
+	OnAttempt      func(Attempt)    // Called after every attempt, e.g. for logging or metrics
+}
+
+// DefaultPolicy is used when Config.Policy is nil
+var DefaultPolicy Policy = Exponential{Base: time.Second, Max: 15 * time.Second, Jitter: 250 * time.Millisecond}
+
+// Attempt describes a finished attempt to an OnAttempt hook
+type Attempt struct {
+	Number  int           // 1 for the first attempt
+	Err     error         // nil when the attempt succeeded
+	Delay   time.Duration // Wait before the next attempt; zero when there is none
+	Elapsed time.Duration // Time since the first attempt started
+	Final   bool          // Whether no further attempt follows
+}
+
+// Do calls op until it succeeds, returns an error that is not retryable, or the limits in cfg are
+// reached, and returns the last error. A *github.RetryAfterError waits as long as it asks for
+// instead of following the policy. Cancelling ctx stops waiting and returns the last error
+// joined with the context's.
+func Do(ctx context.Context, cfg Config, op func(ctx context.Context) error) error {
+	_, err := DoValue(ctx, cfg, func(ctx context.Context) (struct{}, error) {
+		return struct{}{}, op(ctx)
+	})
+	return err
+}
+
+// DoValue is Do for operations that return a value
+func DoValue[T any](ctx context.Context, cfg Config, op func(ctx context.Context) (T, error)) (T, error) {
+	policy := cfg.Policy
+	if policy == nil {
+		policy = DefaultPolicy
+	}
+	retryable := cfg.Retryable
+	if retryable == nil {
+		retryable = isRetryable
+	}
+
+	start := time.Now()
+	var delay time.Duration
+	for attempt := 1; ; attempt++ {
+		if err := ctx.Err(); err != nil {
+			var zero T
+			return zero, err
+		}
+
+		val, err := runAttempt(ctx, cfg.AttemptTimeout, op)
+		info := Attempt{Number: attempt, Err: err, Elapsed: time.Since(start)}
+		if err == nil || !retryable(err) || (cfg.MaxAttempts > 0 && attempt >= cfg.MaxAttempts) {
+			info.Final = true
+			cfg.observe(info)
+			return val, err
+		}
+
+		var raErr *github.RetryAfterError
+		if errors.As(err, &raErr) {
+			delay = raErr.RetryAfter
+		} else {
+			delay = policy.Delay(attempt, delay)
+		}
+		if cfg.MaxElapsed > 0 && info.Elapsed+delay > cfg.MaxElapsed {
+			info.Final = true
+			cfg.observe(info)
+			return val, err
+		}
+
+		info.Delay = delay
+		cfg.observe(info)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return val, errors.Join(err, ctx.Err())
+		case <-timer.C:
+		}
+	}
+}
+
+// runAttempt calls op with a context that expires after timeout, if one is set
+func runAttempt[T any](ctx context.Context, timeout time.Duration, op func(ctx context.Context) (T, error)) (T, error) {
+	if timeout <= 0 {
+		return op(ctx)
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	return op(ctx)
+}
+
+func (cfg Config) observe(a Attempt) {
+	if cfg.OnAttempt != nil {
+		cfg.OnAttempt(a)
+	}
+}
@@ -0,0 +1,63 @@
+package retry
+
+import (
+	"math/rand"
+	"time"
+)
+
+// Policy decides how long to wait before the next attempt
+type Policy interface {
+	// Delay returns the wait after the given number of failed attempts, counting from 1.
+	// prev is the wait before the attempt that just failed, or zero after the first one.
+	Delay(failures int, prev time.Duration) time.Duration
+}
+
+// Exponential doubles the wait after each failure, starting at Base and never exceeding Max.
+// Up to Jitter is added to each wait so that clients failing together do not retry together.
+type Exponential struct {
+	Base   time.Duration
+	Max    time.Duration
+	Jitter time.Duration
+}
+
+// Delay implements Policy
+func (p Exponential) Delay(failures int, prev time.Duration) time.Duration {
+	delay := p.Base * time.Duration(1<<min(failures-1, 30))
+	if p.Jitter > 0 {
+		delay += time.Duration(rand.Int63n(int64(p.Jitter)))
+	}
+	if p.Max > 0 && (delay > p.Max || delay < 0) {
+		return p.Max
+	}
+	return delay
+}
+
+// DecorrelatedJitter picks each wait at random between Base and three times the previous wait,
+// capped at Max. It spreads retries out more than Exponential while still backing off.
+type DecorrelatedJitter struct {
+	Base time.Duration
+	Max  time.Duration
+}
+
+// Delay implements Policy
+func (p DecorrelatedJitter) Delay(failures int, prev time.Duration) time.Duration {
+	prev = max(prev, p.Base)
+	delay := p.Base
+	if upper := prev * 3; upper > p.Base {
+		delay += time.Duration(rand.Int63n(int64(upper - p.Base)))
+	}
+	if p.Max > 0 && delay > p.Max {
+		return p.Max
+	}
+	return delay
+}
+
+// Fixed waits the same time after every failure
+type Fixed struct {
+	Wait time.Duration
+}
+
+// Delay implements Policy
+func (p Fixed) Delay(failures int, prev time.Duration) time.Duration {
+	return p.Wait
+}
@@ -1,65 +1,48 @@
 package retry
 
 import (
+	"context"
 	"errors"
 	"fmt"
-	"math/rand"
 	"net"
-	"strings"
 	"syscall"
 	"time"
 
 	"github.com/babyfaceeasy/repo-scanner/internal/github"
+	"github.com/babyfaceeasy/repo-scanner/internal/model"
 	"github.com/babyfaceeasy/repo-scanner/pkg/logger"
 )
 
 // Retrier decorates a GithubClient with a retry logic
 type Retrier struct {
-	client     github.GitHubClient
-	logger     logger.Logger
-	maxRetries int
-	baseDelay  time.Duration
-	maxDelay   time.Duration
+	client github.GitHubClient
+	logger logger.Logger
+	config Config
 }
 
 // NewRetirer creates a new Retrier Decorator
 func NewRetrier(client github.GitHubClient, logger logger.Logger, maxRetries int, baseDelay, maxDelay time.Duration) *Retrier {
-	return &Retrier{
-		client:     client,
-		logger:     logger,
-		maxRetries: maxRetries,
-		baseDelay:  baseDelay,
-		maxDelay:   maxDelay,
-	}
+	return NewRetrierWithConfig(client, logger, Config{
+		Policy:      Exponential{Base: baseDelay, Max: maxDelay, Jitter: 250 * time.Millisecond},
+		MaxAttempts: maxRetries,
+	})
 }
 
-// DownloadRepo implements GitHubClient with retry logic
-func (r *Retrier) DownloadRepoOLD(cloneURL, destDir string) error {
-	var lastErr error
-	for attempt := 0; attempt <= r.maxRetries; attempt++ {
-		err := r.client.DownloadRepo(cloneURL, destDir)
-		if err == nil {
-			r.logger.Info("Download succeeded")
-			return nil
-		}
-
-		lastErr = err
-		if !isRetryable(err) {
-			r.logger.Warn("Non-retryable error", "error", err, "attempt", attempt+1)
-			return err
-		}
-
-		delay := r.calculateDelay(attempt)
-		r.logger.Info("Retrying after delay", "attempt", attempt+1, "delay_ms", delay.Milliseconds(), "error", err)
-		time.Sleep(delay)
+// NewRetrierWithConfig creates a Retrier that retries calls as described by cfg.
+// Any OnAttempt hook in cfg is called after the Retrier has logged the attempt.
+func NewRetrierWithConfig(client github.GitHubClient, logger logger.Logger, cfg Config) *Retrier {
+	return &Retrier{
+		client: client,
+		logger: logger,
+		config: cfg,
 	}
-
-	r.logger.Error("Max retries exceeded", "error", lastErr, "max_retries", r.maxRetries)
-	return lastErr
 }
 
-// DownloadRepo implements GitHubClient with retry logic
-func (r *Retrier) DownloadRepo(cloneURL, destDir string) (err error) {
+// DownloadRepo implements GitHubClient with retry logic. Each attempt gets its own ctx,
+// bounded by the config's AttemptTimeout. A partial download kept by the client for
+// resuming is discarded once the attempts are over.
+func (r *Retrier) DownloadRepo(ctx context.Context, cloneURL, destDir string) (err error) {
+	defer r.client.DiscardDownload(cloneURL)
 	defer func() {
 		if rec := recover(); rec != nil {
 			r.logger.Error("Recovered from panic", "panic", rec)
@@ -67,102 +50,95 @@ func (r *Retrier) DownloadRepo(cloneURL, destDir string) (err error) {
 		}
 	}()
 
-	var lastErr error
-	for attempt := 0; attempt < r.maxRetries; attempt++ {
-		err := r.client.DownloadRepo(cloneURL, destDir)
-		if err == nil {
-			r.logger.Info("Download succeeded", "attempt", attempt+1)
-			return nil
-		}
+	return Do(ctx, r.configFor("Download"), func(ctx context.Context) error {
+		return r.client.DownloadRepo(ctx, cloneURL, destDir)
+	})
+}
 
-		lastErr = err
+// SubmoduleCommit implements GitHubClient with retry logic
+func (r *Retrier) SubmoduleCommit(ctx context.Context, cloneURL, path string) (string, error) {
+	return DoValue(ctx, r.configFor("Submodule lookup"), func(ctx context.Context) (string, error) {
+		return r.client.SubmoduleCommit(ctx, cloneURL, path)
+	})
+}
 
-		if !isRetryable(err) {
-			r.logger.Warn("Non-retryable error", "error", err, "attempt", attempt+1)
-			return err
-		}
+// Repository implements GitHubClient with retry logic
+func (r *Retrier) Repository(ctx context.Context, cloneURL string) (*model.Repository, error) {
+	return DoValue(ctx, r.configFor("Repository lookup"), func(ctx context.Context) (*model.Repository, error) {
+		return r.client.Repository(ctx, cloneURL)
+	})
+}
 
-		if attempt == r.maxRetries {
-			break
-		}
+// ScanTree implements GitHubClient with retry logic
+func (r *Retrier) ScanTree(ctx context.Context, repo, ref string, threshold github.ThresholdFunc) (*model.Output, error) {
+	return DoValue(ctx, r.configFor("Tree scan"), func(ctx context.Context) (*model.Output, error) {
+		return r.client.ScanTree(ctx, repo, ref, threshold)
+	})
+}
 
-		var delay time.Duration
-		if raErr, ok := err.(*github.RetryAfterError); ok {
-			delay = raErr.RetryAfter
-			r.logger.Warn("Received 429 Too Many Requests", "retry_after_sec", delay.Seconds())
-		} else {
-			delay = r.calculateDelay(attempt)
-		}
+// DiscardDownload implements GitHubClient by delegating to the wrapped client
+func (r *Retrier) DiscardDownload(cloneURL string) {
+	r.client.DiscardDownload(cloneURL)
+}
 
-		r.logger.Info("Retrying after delay", "attempt", attempt+1, "delay_ms", delay.Milliseconds(), "error", err)
-		time.Sleep(delay)
+// configFor returns the Retrier's config with a hook that logs the attempts of op
+func (r *Retrier) configFor(op string) Config {
+	cfg := r.config
+	retryable := cfg.Retryable
+	if retryable == nil {
+		retryable = isRetryable
 	}
-
-	r.logger.Error("Max retries exceeded", "error", lastErr, "max_retries", r.maxRetries)
-	return lastErr
+	hook := cfg.OnAttempt
+	cfg.OnAttempt = func(a Attempt) {
+		r.logAttempt(op, a, retryable)
+		if hook != nil {
+			hook(a)
+		}
+	}
+	return cfg
 }
 
-// calculateDelay computes exponential backoff with jitter
-func (r *Retrier) calculateDelay(attempt int) time.Duration {
-	delay := r.baseDelay * time.Duration(1<<attempt)
-	jitter := time.Duration(rand.Intn(250)) * time.Millisecond
-	final := delay + jitter
-
-	if final > r.maxDelay {
-		return r.maxDelay
+// logAttempt logs the outcome of an attempt made by the Retrier
+func (r *Retrier) logAttempt(op string, a Attempt, retryable func(error) bool) {
+	switch {
+	case a.Err == nil:
+		r.logger.Info(op+" succeeded", "attempt", a.Number)
+	case !a.Final:
+		var raErr *github.RetryAfterError
+		if errors.As(a.Err, &raErr) {
+			r.logger.Warn("Rate limited by GitHub", "status", raErr.StatusCode, "retry_after_sec", raErr.RetryAfter.Seconds())
+		}
+		r.logger.Info("Retrying after delay", "attempt", a.Number, "delay_ms", a.Delay.Milliseconds(), "error", a.Err)
+	case !retryable(a.Err):
+		r.logger.Warn("Non-retryable error", "error", a.Err, "attempt", a.Number)
+	default:
+		r.logger.Error("Max retries exceeded", "error", a.Err, "attempts", a.Number, "elapsed_ms", a.Elapsed.Milliseconds())
 	}
-
-	return final
 }
 
+// isRetryable reports whether another attempt may succeed. Errors from the github package
+// classify themselves through their Retryable method; network errors returned directly by
+// other GitHubClient implementations are classified by type.
 func isRetryable(err error) bool {
 	if err == nil {
 		return false
 	}
 
-	// RetryAfterError from 429
-	var raErr *github.RetryAfterError
-	if errors.As(err, &raErr) {
-		return true
+	var classified interface{ Retryable() bool }
+	if errors.As(err, &classified) {
+		return classified.Retryable()
 	}
 
-	// net.Error (timeouts)
 	var netErr net.Error
 	if errors.As(err, &netErr) && netErr.Timeout() {
 		return true
 	}
 
-	/*
-		// if underlying error is net.Error with Timeout
-		var urlErr *url.Error
-		if errors.As(err, &urlErr) {
-			if nErr, ok := urlErr.Err.(net.Error); ok && nErr.Timeout() {
-				return true
-			}
-		}
-	*/
-
-	// check for common retryable error substrings
-	errStr := strings.ToLower(err.Error())
-	retryableSubstrings := []string{
-		"connection reset",
-		"connection refused",
-		"timeout",
-		"temporary failure",
-		"tls handshake timeout",
-		"eof",
-		"no such host",
-		"broken pipe",
-		"i/o timeout",
-	}
-
-	for _, substr := range retryableSubstrings {
-		if strings.Contains(errStr, substr) {
-			return true
-		}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return true
 	}
 
-	// check for retryable syscall errors
 	var errno syscall.Errno
 	if errors.As(err, &errno) {
 		switch errno {
@@ -1,20 +1,48 @@
 package retry
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
 	"strings"
+	"syscall"
 	"testing"
 	"time"
 
 	"github.com/babyfaceeasy/repo-scanner/internal/github"
+	"github.com/babyfaceeasy/repo-scanner/internal/model"
 )
 
 type mockGitHubClient struct {
-	downloadFunc func(cloneURL, destDir string) error
+	downloadFunc  func(ctx context.Context, cloneURL, destDir string) error
+	submoduleFunc func(ctx context.Context, cloneURL, path string) (string, error)
+	repoFunc      func(ctx context.Context, cloneURL string) (*model.Repository, error)
+	treeFunc      func(ctx context.Context, repo, ref string, threshold github.ThresholdFunc) (*model.Output, error)
+	discarded     []string
 }
 
-func (m *mockGitHubClient) DownloadRepo(cloneURL, destDir string) error {
-	return m.downloadFunc(cloneURL, destDir)
+func (m *mockGitHubClient) DownloadRepo(ctx context.Context, cloneURL, destDir string) error {
+	return m.downloadFunc(ctx, cloneURL, destDir)
+}
+
+func (m *mockGitHubClient) SubmoduleCommit(ctx context.Context, cloneURL, path string) (string, error) {
+	return m.submoduleFunc(ctx, cloneURL, path)
+}
+
+func (m *mockGitHubClient) DiscardDownload(cloneURL string) {
+	m.discarded = append(m.discarded, cloneURL)
+}
+
+func (m *mockGitHubClient) Repository(ctx context.Context, cloneURL string) (*model.Repository, error) {
+	return m.repoFunc(ctx, cloneURL)
+}
+
+func (m *mockGitHubClient) ScanTree(ctx context.Context, repo, ref string, threshold github.ThresholdFunc) (*model.Output, error) {
+	return m.treeFunc(ctx, repo, ref, threshold)
 }
 
 type mockLogger struct {
@@ -30,15 +58,15 @@ func (m *mockLogger) Fatal(msg string, fields ...interface{}) { m.logs = append(
 func TestRetrier_DownloadRepo(t *testing.T) {
 	tests := []struct {
 		name          string
-		setupFunc     func(*int) func(string, string) error
+		setupFunc     func(*int) func(context.Context, string, string) error
 		wantErr       bool
 		wantAttempts  int
 		expectLogPart string
 	}{
 		{
 			name: "Immediate success",
-			setupFunc: func(attempts *int) func(string, string) error {
-				return func(cloneURL, destDir string) error {
+			setupFunc: func(attempts *int) func(context.Context, string, string) error {
+				return func(ctx context.Context, cloneURL, destDir string) error {
 					*attempts++
 					return nil
 				}
@@ -49,8 +77,8 @@ func TestRetrier_DownloadRepo(t *testing.T) {
 		},
 		{
 			name: "Success after retries",
-			setupFunc: func(attempts *int) func(string, string) error {
-				return func(cloneURL, destDir string) error {
+			setupFunc: func(attempts *int) func(context.Context, string, string) error {
+				return func(ctx context.Context, cloneURL, destDir string) error {
 					*attempts++
 					if *attempts < 3 {
 						return &github.RetryAfterError{
@@ -67,8 +95,8 @@ func TestRetrier_DownloadRepo(t *testing.T) {
 		},
 		{
 			name: "Failure after max retries",
-			setupFunc: func(attempts *int) func(string, string) error {
-				return func(cloneURL, destDir string) error {
+			setupFunc: func(attempts *int) func(context.Context, string, string) error {
+				return func(ctx context.Context, cloneURL, destDir string) error {
 					*attempts++
 					return &github.RetryAfterError{
 						Err:        errors.New("rate limit"),
@@ -82,8 +110,8 @@ func TestRetrier_DownloadRepo(t *testing.T) {
 		},
 		{
 			name: "Non-retryable error",
-			setupFunc: func(attempts *int) func(string, string) error {
-				return func(cloneURL, destDir string) error {
+			setupFunc: func(attempts *int) func(context.Context, string, string) error {
+				return func(ctx context.Context, cloneURL, destDir string) error {
 					*attempts++
 					return errors.New("fatal config error")
 				}
@@ -104,7 +132,7 @@ func TestRetrier_DownloadRepo(t *testing.T) {
 
 			retrier := NewRetrier(mockClient, mockLog, 3, 10*time.Millisecond, 1*time.Second)
 
-			err := retrier.DownloadRepo("https://github.com/owner/repo.git", "some/dest")
+			err := retrier.DownloadRepo(context.Background(), "https://github.com/owner/repo.git", "some/dest")
 			if (err != nil) != tt.wantErr {
 				t.Errorf("DownloadRepo() error = %v, wantErr %v", err, tt.wantErr)
 			}
@@ -113,6 +141,10 @@ func TestRetrier_DownloadRepo(t *testing.T) {
 				t.Errorf("attempts = %d, want %d", attempts, tt.wantAttempts)
 			}
 
+			if len(mockClient.discarded) != 1 {
+				t.Errorf("DiscardDownload() called %d times, want once", len(mockClient.discarded))
+			}
+
 			if !containsLog(mockLog.logs, tt.expectLogPart) {
 				t.Errorf("Expected log containing %q, got logs: %v", tt.expectLogPart, mockLog.logs)
 			}
@@ -128,3 +160,378 @@ func containsLog(logs []string, substr string) bool {
 	}
 	return false
 }
+
+func TestCircuitBreaker(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	var fail bool
+	calls := 0
+	mockClient := &mockGitHubClient{
+		downloadFunc: func(ctx context.Context, cloneURL, destDir string) error {
+			calls++
+			if fail {
+				return &github.NetworkError{Op: "fetching tarball", Err: syscall.ECONNREFUSED}
+			}
+			return nil
+		},
+	}
+	mockLog := &mockLogger{}
+	cb := NewCircuitBreaker(mockClient, mockLog, 0.5, 4, 30*time.Second)
+	cb.now = func() time.Time { return now }
+
+	// one failure in four calls stays below the ratio
+	for _, f := range []bool{false, true, false, false} {
+		fail = f
+		cb.DownloadRepo(context.Background(), "https://github.com/owner/repo.git", "/tmp")
+	}
+	if cb.State() != StateClosed {
+		t.Fatalf("State() = %v, want closed", cb.State())
+	}
+
+	// two failures among the last four calls open the circuit
+	fail = true
+	cb.DownloadRepo(context.Background(), "https://github.com/owner/repo.git", "/tmp")
+	if cb.State() != StateOpen {
+		t.Fatalf("State() = %v, want open", cb.State())
+	}
+
+	calls = 0
+	if err := cb.DownloadRepo(context.Background(), "https://github.com/owner/repo.git", "/tmp"); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("DownloadRepo() error = %v, want ErrCircuitOpen", err)
+	}
+	if calls != 0 {
+		t.Errorf("client called %d times while open, want 0", calls)
+	}
+
+	// after the cool-down a failed trial reopens the circuit
+	now = now.Add(30 * time.Second)
+	if cb.State() != StateHalfOpen {
+		t.Fatalf("State() = %v, want half-open", cb.State())
+	}
+	cb.DownloadRepo(context.Background(), "https://github.com/owner/repo.git", "/tmp")
+	if cb.State() != StateOpen || calls != 1 {
+		t.Fatalf("State() = %v after %d calls, want open after one trial", cb.State(), calls)
+	}
+
+	// and a successful trial closes it
+	now = now.Add(30 * time.Second)
+	fail = false
+	if err := cb.DownloadRepo(context.Background(), "https://github.com/owner/repo.git", "/tmp"); err != nil {
+		t.Errorf("DownloadRepo() error = %v", err)
+	}
+	if cb.State() != StateClosed {
+		t.Errorf("State() = %v, want closed", cb.State())
+	}
+
+	if !containsLog(mockLog.logs, "Circuit breaker state changed") {
+		t.Errorf("Expected state transitions to be logged, got logs: %v", mockLog.logs)
+	}
+}
+
+func TestCircuitBreakerInterleavedCalls(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	started := make(chan string)
+	results := map[string]chan error{"slow": make(chan error), "trial": make(chan error)}
+	mockClient := &mockGitHubClient{
+		downloadFunc: func(ctx context.Context, cloneURL, destDir string) error {
+			if ch, ok := results[destDir]; ok {
+				started <- destDir
+				return <-ch
+			}
+			return &github.NetworkError{Op: "fetching tarball", Err: syscall.ECONNREFUSED}
+		},
+	}
+	cb := NewCircuitBreaker(mockClient, &mockLogger{}, 1, 1, 30*time.Second)
+	cb.now = func() time.Time { return now }
+
+	// a slow call starts while the circuit is closed
+	slow := make(chan error)
+	go func() { slow <- cb.DownloadRepo(context.Background(), "https://github.com/owner/repo.git", "slow") }()
+	<-started
+
+	// meanwhile a failure opens the circuit and the cool-down passes
+	cb.DownloadRepo(context.Background(), "https://github.com/owner/repo.git", "fail")
+	now = now.Add(30 * time.Second)
+	trial := make(chan error)
+	go func() { trial <- cb.DownloadRepo(context.Background(), "https://github.com/owner/repo.git", "trial") }()
+	<-started
+
+	// the slow call succeeding says nothing about the trial
+	results["slow"] <- nil
+	<-slow
+	if cb.State() != StateHalfOpen {
+		t.Fatalf("State() = %v after the slow call, want half-open", cb.State())
+	}
+	if err := cb.DownloadRepo(context.Background(), "https://github.com/owner/repo.git", "other"); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("DownloadRepo() during the trial error = %v, want ErrCircuitOpen", err)
+	}
+
+	results["trial"] <- &github.NetworkError{Op: "fetching tarball", Err: syscall.ECONNREFUSED}
+	<-trial
+	if cb.State() != StateOpen {
+		t.Errorf("State() = %v after the failed trial, want open", cb.State())
+	}
+}
+
+func TestCircuitBreakerIgnoresPermanentErrors(t *testing.T) {
+	mockClient := &mockGitHubClient{
+		downloadFunc: func(ctx context.Context, cloneURL, destDir string) error {
+			return &github.NotFoundError{URL: "https://api.github.com/repos/owner/missing/tarball"}
+		},
+	}
+	cb := NewCircuitBreaker(mockClient, &mockLogger{}, 0.5, 2, time.Minute)
+	for i := 0; i < 5; i++ {
+		cb.DownloadRepo(context.Background(), "https://github.com/owner/missing.git", "/tmp")
+	}
+	if cb.State() != StateClosed {
+		t.Errorf("State() = %v, want closed after permanent errors", cb.State())
+	}
+}
+
+func TestRetrierStopsOnOpenCircuit(t *testing.T) {
+	calls := 0
+	mockClient := &mockGitHubClient{
+		downloadFunc: func(ctx context.Context, cloneURL, destDir string) error {
+			calls++
+			return &github.NetworkError{Op: "fetching tarball", Err: syscall.ECONNRESET}
+		},
+	}
+	mockLog := &mockLogger{}
+	cb := NewCircuitBreaker(mockClient, mockLog, 1, 1, time.Minute)
+	retrier := NewRetrier(cb, mockLog, 3, time.Millisecond, 10*time.Millisecond)
+
+	if err := retrier.DownloadRepo(context.Background(), "https://github.com/owner/repo.git", "/tmp"); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("DownloadRepo() error = %v, want ErrCircuitOpen", err)
+	}
+	if calls != 1 {
+		t.Errorf("client called %d times, want 1", calls)
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	dialErr := &url.Error{Op: "Get", URL: "https://api.github.com", Err: &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}}
+	timeoutErr := &url.Error{Op: "Get", URL: "https://api.github.com", Err: &net.OpError{Op: "read", Net: "tcp", Err: os.ErrDeadlineExceeded}}
+
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "nil", err: nil, want: false},
+		{name: "rate limited", err: &github.RetryAfterError{Err: errors.New("rate limited"), RetryAfter: time.Second}, want: true},
+		{name: "500", err: &github.HTTPStatusError{StatusCode: 500}, want: true},
+		{name: "502", err: &github.HTTPStatusError{StatusCode: 502}, want: true},
+		{name: "503 wrapped", err: fmt.Errorf("downloading: %w", &github.HTTPStatusError{StatusCode: 503}), want: true},
+		{name: "408", err: &github.HTTPStatusError{StatusCode: 408}, want: true},
+		{name: "422", err: &github.HTTPStatusError{StatusCode: 422}, want: false},
+		{name: "404", err: &github.NotFoundError{URL: "https://api.github.com/repos/owner/timeout/tarball"}, want: false},
+		{name: "401", err: &github.UnauthorizedError{StatusCode: 401}, want: false},
+		{name: "403", err: &github.UnauthorizedError{StatusCode: 403}, want: false},
+		{name: "connection refused", err: &github.NetworkError{Op: "fetching tarball", Err: dialErr}, want: true},
+		{name: "timeout", err: &github.NetworkError{Op: "fetching tarball", Err: timeoutErr}, want: true},
+		{name: "dns failure", err: &github.NetworkError{Op: "fetching tarball", Err: &net.DNSError{Err: "server misbehaving", Name: "api.github.com"}}, want: true},
+		{name: "invalid request", err: &github.NetworkError{Op: "fetching tarball", Err: errors.New("unsupported protocol scheme")}, want: false},
+		{name: "truncated stream", err: &github.ExtractionError{Err: fmt.Errorf("reading tarball: %w", io.ErrUnexpectedEOF)}, want: true},
+		{name: "connection reset during extraction", err: &github.ExtractionError{Path: "a.txt", Err: syscall.ECONNRESET}, want: true},
+		{name: "illegal path", err: &github.ExtractionError{Path: "../etc/passwd", Err: errors.New("illegal file path")}, want: false},
+		{name: "disk full", err: &github.ExtractionError{Path: "big.bin", Err: syscall.ENOSPC}, want: false},
+		{name: "bare timeout from another client", err: timeoutErr, want: true},
+		{name: "bare errno from another client", err: syscall.EPIPE, want: true},
+		{name: "message mentioning timeout", err: errors.New("open /repo/timeout/config: permission denied"), want: false},
+		{name: "message mentioning eof", err: errors.New("parsing geofence.json"), want: false},
+		{name: "circuit open", err: ErrCircuitOpen, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isRetryable(tt.err); got != tt.want {
+				t.Errorf("isRetryable(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPolicies(t *testing.T) {
+	exp := Exponential{Base: 100 * time.Millisecond, Max: time.Second}
+	for failures, want := range map[int]time.Duration{1: 100 * time.Millisecond, 2: 200 * time.Millisecond, 4: 800 * time.Millisecond, 5: time.Second, 64: time.Second} {
+		if got := exp.Delay(failures, 0); got != want {
+			t.Errorf("Exponential.Delay(%d) = %v, want %v", failures, got, want)
+		}
+	}
+
+	jittered := Exponential{Base: 100 * time.Millisecond, Max: time.Second, Jitter: 50 * time.Millisecond}
+	if got := jittered.Delay(1, 0); got < 100*time.Millisecond || got >= 150*time.Millisecond {
+		t.Errorf("Exponential.Delay with jitter = %v, want within [100ms, 150ms)", got)
+	}
+
+	dj := DecorrelatedJitter{Base: 100 * time.Millisecond, Max: time.Second}
+	prev := time.Duration(0)
+	for i := 1; i <= 20; i++ {
+		got := dj.Delay(i, prev)
+		if got < dj.Base || got > dj.Max || got > max(prev, dj.Base)*3 {
+			t.Fatalf("DecorrelatedJitter.Delay(%d, %v) = %v, out of range", i, prev, got)
+		}
+		prev = got
+	}
+
+	if got := (Fixed{Wait: 300 * time.Millisecond}).Delay(7, time.Minute); got != 300*time.Millisecond {
+		t.Errorf("Fixed.Delay = %v, want 300ms", got)
+	}
+}
+
+func TestDo(t *testing.T) {
+	transient := &github.NetworkError{Op: "fetching", Err: syscall.ECONNRESET}
+
+	tests := []struct {
+		name         string
+		cfg          Config
+		failures     int // attempts that fail with a transient error before succeeding
+		err          error
+		wantErr      bool
+		wantAttempts int
+	}{
+		{name: "succeeds after failures", cfg: Config{Policy: Fixed{}, MaxAttempts: 5}, failures: 2, wantAttempts: 3},
+		{name: "stops at max attempts", cfg: Config{Policy: Fixed{}, MaxAttempts: 3}, failures: 10, wantErr: true, wantAttempts: 3},
+		{name: "stops on permanent error", cfg: Config{Policy: Fixed{}, MaxAttempts: 3}, err: errors.New("bad config"), wantErr: true, wantAttempts: 1},
+		{name: "stops before waiting past max elapsed", cfg: Config{Policy: Fixed{Wait: time.Hour}, MaxElapsed: time.Minute}, failures: 100, wantErr: true, wantAttempts: 1},
+		{name: "custom classification", cfg: Config{Policy: Fixed{}, MaxAttempts: 3, Retryable: func(error) bool { return true }}, err: errors.New("bad config"), wantErr: true, wantAttempts: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var observed []Attempt
+			tt.cfg.OnAttempt = func(a Attempt) { observed = append(observed, a) }
+
+			attempts := 0
+			err := Do(context.Background(), tt.cfg, func(context.Context) error {
+				attempts++
+				if tt.err != nil {
+					return tt.err
+				}
+				if attempts <= tt.failures {
+					return transient
+				}
+				return nil
+			})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Do() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if attempts != tt.wantAttempts {
+				t.Errorf("attempts = %d, want %d", attempts, tt.wantAttempts)
+			}
+			if len(observed) != attempts {
+				t.Fatalf("OnAttempt called %d times, want %d", len(observed), attempts)
+			}
+			for i, a := range observed {
+				if a.Number != i+1 || a.Final != (i == len(observed)-1) {
+					t.Errorf("attempt %d observed as %+v", i+1, a)
+				}
+			}
+		})
+	}
+}
+
+func TestDoValueRetryAfter(t *testing.T) {
+	var delays []time.Duration
+	cfg := Config{
+		Policy:      Fixed{Wait: time.Hour},
+		MaxAttempts: 2,
+		OnAttempt:   func(a Attempt) { delays = append(delays, a.Delay) },
+	}
+	calls := 0
+	sha, err := DoValue(context.Background(), cfg, func(context.Context) (string, error) {
+		calls++
+		if calls == 1 {
+			return "", &github.RetryAfterError{Err: errors.New("rate limited"), RetryAfter: 10 * time.Millisecond}
+		}
+		return "abc123", nil
+	})
+	if err != nil || sha != "abc123" {
+		t.Fatalf("DoValue() = %q, %v, want abc123", sha, err)
+	}
+	if delays[0] != 10*time.Millisecond {
+		t.Errorf("delay = %v, want the 10ms GitHub asked for", delays[0])
+	}
+}
+
+func TestDoContext(t *testing.T) {
+	// each attempt gets its own deadline
+	attempts := 0
+	err := Do(context.Background(), Config{Policy: Fixed{}, MaxAttempts: 2, AttemptTimeout: 10 * time.Millisecond}, func(ctx context.Context) error {
+		attempts++
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	if !errors.Is(err, context.DeadlineExceeded) || attempts != 2 {
+		t.Errorf("Do() = %v after %d attempts, want a deadline error after 2", err, attempts)
+	}
+
+	// cancelling the parent stops the wait between attempts
+	ctx, cancel := context.WithCancel(context.Background())
+	transient := &github.NetworkError{Op: "fetching", Err: syscall.ECONNRESET}
+	start := time.Now()
+	err = Do(ctx, Config{Policy: Fixed{Wait: time.Hour}}, func(context.Context) error {
+		time.AfterFunc(10*time.Millisecond, cancel)
+		return transient
+	})
+	if !errors.Is(err, context.Canceled) || !errors.Is(err, transient) {
+		t.Errorf("Do() error = %v, want the last error joined with context.Canceled", err)
+	}
+	if time.Since(start) > time.Second {
+		t.Errorf("Do() kept waiting after the context was cancelled")
+	}
+}
+
+func TestRetrierSubmoduleCommit(t *testing.T) {
+	calls := 0
+	mockClient := &mockGitHubClient{
+		submoduleFunc: func(ctx context.Context, cloneURL, path string) (string, error) {
+			calls++
+			if calls < 2 {
+				return "", &github.HTTPStatusError{StatusCode: 502}
+			}
+			return "abc123", nil
+		},
+	}
+	mockLog := &mockLogger{}
+	retrier := NewRetrierWithConfig(mockClient, mockLog, Config{Policy: Fixed{}, MaxAttempts: 3})
+
+	sha, err := retrier.SubmoduleCommit(context.Background(), "https://github.com/owner/repo.git", "libs/dep")
+	if err != nil || sha != "abc123" || calls != 2 {
+		t.Errorf("SubmoduleCommit() = %q, %v after %d calls, want abc123 after 2", sha, err, calls)
+	}
+	if !containsLog(mockLog.logs, "Submodule lookup succeeded") {
+		t.Errorf("Expected success to be logged, got logs: %v", mockLog.logs)
+	}
+}
+
+func TestRetrierContext(t *testing.T) {
+	calls := 0
+	mockClient := &mockGitHubClient{}
+	mockClient.downloadFunc = func(ctx context.Context, cloneURL, destDir string) error {
+		calls++
+		if _, ok := ctx.Deadline(); !ok {
+			t.Error("attempt context has no deadline, want AttemptTimeout applied")
+		}
+		if calls < 2 {
+			return &github.RetryAfterError{Err: errors.New("secondary rate limit exceeded"), StatusCode: 403}
+		}
+		return nil
+	}
+	mockLog := &mockLogger{}
+	retrier := NewRetrierWithConfig(mockClient, mockLog, Config{Policy: Fixed{}, MaxAttempts: 3, AttemptTimeout: time.Minute})
+
+	if err := retrier.DownloadRepo(context.Background(), "https://github.com/owner/repo.git", t.TempDir()); err != nil || calls != 2 {
+		t.Errorf("DownloadRepo() = %v after %d calls, want success after 2", err, calls)
+	}
+	if !containsLog(mockLog.logs, "Rate limited by GitHub") {
+		t.Errorf("Expected the rate limit to be logged, got logs: %v", mockLog.logs)
+	}
+
+	// a cancelled context stops the retries before the client is called
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	calls = 0
+	if err := retrier.DownloadRepo(ctx, "https://github.com/owner/repo.git", t.TempDir()); !errors.Is(err, context.Canceled) || calls != 0 {
+		t.Errorf("DownloadRepo() with a cancelled context = %v after %d calls, want context.Canceled and no calls", err, calls)
+	}
+}
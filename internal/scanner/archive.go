@@ -0,0 +1,63 @@
+package scanner
+
+import (
+	"errors"
+	"path/filepath"
+
+	"github.com/babyfaceeasy/repo-scanner/internal/archive"
+	"github.com/babyfaceeasy/repo-scanner/internal/model"
+)
+
+// scanArchive returns the entries of the archive at path that exceed their threshold, named
+// relPath!/inner/path. Entries are classified from their leading bytes since they are never
+// extracted. Archives that are corrupt or exceed a limit are skipped with a warning, keeping
+// the entries found before the problem.
+func (s *Scanner) scanArchive(path, relPath string, rules *ruleSet, opts Options) []model.FileInfo {
+	limits := archive.DefaultLimits
+	if opts.ArchiveDepth > 0 {
+		limits.MaxDepth = opts.ArchiveDepth
+	}
+
+	var files []model.FileInfo
+	err := archive.Walk(path, relPath, limits, func(e archive.Entry) error {
+		name := relPath + archive.Separator + e.Path
+		slashName := filepath.ToSlash(name)
+		rule, sizeThreshold := rules.threshold(slashName)
+		if sizeThreshold <= 0 || e.Size <= sizeThreshold {
+			return nil
+		}
+
+		vendored := IsVendored(slashName)
+		generated := hasGeneratedName(slashName) || hasGeneratedHead(slashName, e.Head)
+		if (vendored && opts.ExcludeVendored) || (generated && opts.ExcludeGenerated) {
+			s.logger.Debug("Skipping non first-party file", "path", name, "vendored", vendored, "generated", generated)
+			return nil
+		}
+
+		s.logger.Info("Found large file", "path", name, "size", e.Size)
+
+		mimeType, category, binary := classify(e.Head[:min(len(e.Head), sniffLen)], filepath.Ext(e.Path))
+		files = append(files, model.FileInfo{
+			Name:      name,
+			Size:      e.Size,
+			Rule:      rule,
+			Threshold: sizeThreshold,
+			MIMEType:  mimeType,
+			Category:  category,
+			Binary:    binary,
+			Vendored:  vendored,
+			Generated: generated,
+			Archive:   relPath,
+		})
+		return nil
+	})
+
+	var limitErr *archive.LimitError
+	switch {
+	case errors.As(err, &limitErr):
+		s.logger.Warn("Archive limit exceeded, skipping the rest of it", "path", relPath, "reason", limitErr.Reason)
+	case err != nil:
+		s.logger.Warn("Failed to read archive", "path", relPath, "error", err)
+	}
+	return files
+}
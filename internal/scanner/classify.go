@@ -0,0 +1,177 @@
+package scanner
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/babyfaceeasy/repo-scanner/internal/model"
+)
+
+// sniffLen is the number of leading bytes inspected, matching http.DetectContentType
+const sniffLen = 512
+
+// signature identifies a file type by the bytes at a fixed offset
+type signature struct {
+	offset   int
+	magic    []byte
+	mimeType string
+}
+
+// signatures covers types that http.DetectContentType does not know about
+var signatures = []signature{
+	{0, []byte("SQLite format 3\x00"), "application/vnd.sqlite3"},
+	{0, []byte("PGDMP"), "application/x-postgresql-dump"},
+	{0, []byte("7z\xBC\xAF\x27\x1C"), "application/x-7z-compressed"},
+	{0, []byte("\xFD7zXZ\x00"), "application/x-xz"},
+	{0, []byte("\x28\xB5\x2F\xFD"), "application/zstd"},
+	{0, []byte("BZh"), "application/x-bzip2"},
+	{257, []byte("ustar"), "application/x-tar"},
+	{0, []byte("\x7FELF"), "application/x-elf"},
+	{0, []byte("\xCF\xFA\xED\xFE"), "application/x-mach-binary"},
+	{0, []byte("\xCE\xFA\xED\xFE"), "application/x-mach-binary"},
+	{0, []byte("MZ"), "application/vnd.microsoft.portable-executable"},
+}
+
+// sqlDumpMarkers identify plain text database dumps
+var sqlDumpMarkers = [][]byte{
+	[]byte("-- MySQL dump"),
+	[]byte("-- MariaDB dump"),
+	[]byte("-- PostgreSQL database dump"),
+}
+
+var categoryByMIME = map[string]string{
+	"application/zip":                               model.CategoryArchive,
+	"application/x-gzip":                            model.CategoryArchive,
+	"application/x-rar-compressed":                  model.CategoryArchive,
+	"application/x-tar":                             model.CategoryArchive,
+	"application/x-7z-compressed":                   model.CategoryArchive,
+	"application/x-xz":                              model.CategoryArchive,
+	"application/zstd":                              model.CategoryArchive,
+	"application/x-bzip2":                           model.CategoryArchive,
+	"application/vnd.sqlite3":                       model.CategoryDatabase,
+	"application/x-postgresql-dump":                 model.CategoryDatabase,
+	"application/sql":                               model.CategoryDatabase,
+	"application/pdf":                               model.CategoryDocument,
+	"application/postscript":                        model.CategoryDocument,
+	"application/x-elf":                             model.CategoryExecutable,
+	"application/x-mach-binary":                     model.CategoryExecutable,
+	"application/wasm":                              model.CategoryExecutable,
+	"application/vnd.microsoft.portable-executable": model.CategoryExecutable,
+}
+
+// classifyFile sniffs the first bytes of the file at path and returns its MIME type,
+// category and whether it is binary
+func classifyFile(path string) (string, string, bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", "", false, err
+	}
+	defer f.Close()
+
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", "", false, err
+	}
+	mimeType, category, binary := classify(head[:n], filepath.Ext(path))
+	return mimeType, category, binary, nil
+}
+
+// classify determines the MIME type from content first and falls back to the extension
+// only for text and unrecognised content, where sniffing is least precise
+func classify(head []byte, ext string) (string, string, bool) {
+	mimeType := sniff(head)
+	binary := !strings.HasPrefix(mimeType, "text/")
+
+	switch {
+	case !binary && isSQLDump(head, ext):
+		mimeType = "application/sql"
+	case !binary:
+		if byExt := mime.TypeByExtension(strings.ToLower(ext)); isTextual(byExt) {
+			mimeType = byExt
+		}
+	case mimeType == "application/octet-stream":
+		if byExt := mime.TypeByExtension(strings.ToLower(ext)); byExt != "" && !isTextual(byExt) {
+			mimeType = byExt
+		}
+	}
+
+	return mimeType, category(mimeType, binary), binary
+}
+
+func sniff(head []byte) string {
+	for _, sig := range signatures {
+		if len(head) >= sig.offset+len(sig.magic) && bytes.Equal(head[sig.offset:sig.offset+len(sig.magic)], sig.magic) {
+			return sig.mimeType
+		}
+	}
+	return http.DetectContentType(head)
+}
+
+// isTextual reports whether a MIME type describes text content
+func isTextual(mimeType string) bool {
+	return strings.HasPrefix(mimeType, "text/") ||
+		strings.Contains(mimeType, "json") ||
+		strings.Contains(mimeType, "xml") ||
+		strings.Contains(mimeType, "javascript")
+}
+
+func isSQLDump(head []byte, ext string) bool {
+	if strings.EqualFold(ext, ".sql") {
+		return true
+	}
+	for _, marker := range sqlDumpMarkers {
+		if bytes.Contains(head, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+func category(mimeType string, binary bool) string {
+	base, _, _ := strings.Cut(mimeType, ";")
+	if c, ok := categoryByMIME[base]; ok {
+		return c
+	}
+	switch {
+	case strings.HasPrefix(base, "image/"):
+		return model.CategoryImage
+	case strings.HasPrefix(base, "video/"):
+		return model.CategoryVideo
+	case strings.HasPrefix(base, "audio/"):
+		return model.CategoryAudio
+	case !binary:
+		return model.CategoryText
+	default:
+		return model.CategoryBinary
+	}
+}
+
+// categoryFilter keeps files whose category is included and not excluded
+type categoryFilter struct {
+	include map[string]bool
+	exclude map[string]bool
+}
+
+func newCategoryFilter(include, exclude []string) *categoryFilter {
+	toSet := func(list []string) map[string]bool {
+		set := make(map[string]bool, len(list))
+		for _, c := range list {
+			set[c] = true
+		}
+		return set
+	}
+	return &categoryFilter{include: toSet(include), exclude: toSet(exclude)}
+}
+
+func (f *categoryFilter) keep(category string) bool {
+	if len(f.include) > 0 && !f.include[category] {
+		return false
+	}
+	return !f.exclude[category]
+}
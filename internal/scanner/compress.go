@@ -0,0 +1,127 @@
+package scanner
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/babyfaceeasy/repo-scanner/internal/model"
+)
+
+const (
+	compressWorkerCount = 4
+	zstdWindowSize      = 8 << 20 // caps the encoder's memory per worker
+)
+
+// countingWriter discards what is written to it and counts the bytes
+type countingWriter struct {
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	return len(p), nil
+}
+
+// compressor measures the gzip and zstd compressed size of a file. The encoders and
+// the read buffer are reused between files and the output is discarded, so memory
+// does not grow with file size.
+type compressor struct {
+	gz  *gzip.Writer
+	zs  *zstd.Encoder
+	buf []byte
+	gzN countingWriter
+	zsN countingWriter
+}
+
+func newCompressor() (*compressor, error) {
+	c := &compressor{buf: make([]byte, hashBufferSize)}
+	c.gz = gzip.NewWriter(&c.gzN)
+	zs, err := zstd.NewWriter(&c.zsN, zstd.WithEncoderConcurrency(1), zstd.WithWindowSize(zstdWindowSize))
+	if err != nil {
+		return nil, err
+	}
+	c.zs = zs
+	return c, nil
+}
+
+// sizes returns the gzip and zstd compressed sizes of the file at path
+func (c *compressor) sizes(path string) (int64, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+
+	c.gzN, c.zsN = countingWriter{}, countingWriter{}
+	c.gz.Reset(&c.gzN)
+	c.zs.Reset(&c.zsN)
+
+	if _, err := io.CopyBuffer(io.MultiWriter(c.gz, c.zs), f, c.buf); err != nil {
+		return 0, 0, err
+	}
+	if err := c.gz.Close(); err != nil {
+		return 0, 0, err
+	}
+	if err := c.zs.Close(); err != nil {
+		return 0, 0, err
+	}
+	return c.gzN.n, c.zsN.n, nil
+}
+
+// compressFiles sets the compressed sizes and compression ratio of each file on disk concurrently
+func compressFiles(root string, files []model.FileInfo) error {
+	indexes := make(chan int)
+	errChan := make(chan error, compressWorkerCount)
+	var wg sync.WaitGroup
+
+	for i := 0; i < compressWorkerCount; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c, err := newCompressor()
+			if err == nil {
+				defer c.zs.Close()
+			}
+			for idx := range indexes {
+				if err == nil {
+					files[idx].GzipSize, files[idx].ZstdSize, err = c.sizes(filepath.Join(root, files[idx].Name))
+				}
+				if err != nil {
+					errChan <- fmt.Errorf("compressing %s: %w", files[idx].Name, err)
+					// drain so the producer is never blocked
+					for range indexes {
+					}
+					return
+				}
+				files[idx].CompressionRatio = compressionRatio(files[idx].Size, min(files[idx].GzipSize, files[idx].ZstdSize))
+			}
+		}()
+	}
+
+	for i := range files {
+		// archive entries are never extracted, so they have no contents to read
+		if files[i].Archive == "" {
+			indexes <- i
+		}
+	}
+	close(indexes)
+	wg.Wait()
+	close(errChan)
+
+	return <-errChan
+}
+
+// compressionRatio returns how many times smaller the compressed size is, to two decimals
+func compressionRatio(size, compressed int64) float64 {
+	if compressed == 0 {
+		return 0
+	}
+	return math.Round(float64(size)/float64(compressed)*100) / 100
+}
@@ -0,0 +1,59 @@
+package scanner
+
+import (
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/babyfaceeasy/repo-scanner/internal/model"
+)
+
+// dirTotals rolls file sizes up into their ancestor directories down to a fixed depth
+type dirTotals struct {
+	depth int
+	dirs  map[string]*model.DirInfo
+}
+
+func newDirTotals(depth int) *dirTotals {
+	return &dirTotals{
+		depth: depth,
+		dirs:  make(map[string]*model.DirInfo),
+	}
+}
+
+// add counts a file, given as a slash separated relative path, towards each of its ancestors
+func (d *dirTotals) add(relPath string, size int64) {
+	parts := strings.Split(path.Dir(relPath), "/")
+	if parts[0] == "." {
+		return // files in the repository root have no directory to roll up into
+	}
+
+	for i := 1; i <= len(parts) && i <= d.depth; i++ {
+		dir := strings.Join(parts[:i], "/")
+		info, ok := d.dirs[dir]
+		if !ok {
+			info = &model.DirInfo{Path: dir}
+			d.dirs[dir] = info
+		}
+		info.Size += size
+		info.Files++
+	}
+}
+
+// largest returns up to limit directories ordered by size, largest first
+func (d *dirTotals) largest(limit int) []model.DirInfo {
+	out := make([]model.DirInfo, 0, len(d.dirs))
+	for _, info := range d.dirs {
+		out = append(out, *info)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Size != out[j].Size {
+			return out[i].Size > out[j].Size
+		}
+		return out[i].Path < out[j].Path
+	})
+	if len(out) > limit {
+		out = out[:limit]
+	}
+	return out
+}
@@ -0,0 +1,105 @@
+package scanner
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/babyfaceeasy/repo-scanner/internal/model"
+)
+
+const (
+	hashWorkerCount = 4
+	hashBufferSize  = 64 * 1024
+)
+
+// hashFiles computes the SHA-256 of each file on disk concurrently. Every worker streams
+// files through its own fixed-size buffer, so memory does not grow with file size.
+func hashFiles(root string, files []model.FileInfo) error {
+	indexes := make(chan int)
+	errChan := make(chan error, hashWorkerCount)
+	var wg sync.WaitGroup
+
+	for i := 0; i < hashWorkerCount; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			buf := make([]byte, hashBufferSize)
+			for idx := range indexes {
+				hash, err := hashFile(filepath.Join(root, files[idx].Name), buf)
+				if err != nil {
+					errChan <- fmt.Errorf("hashing %s: %w", files[idx].Name, err)
+					// drain so the producer is never blocked
+					for range indexes {
+					}
+					return
+				}
+				files[idx].Hash = hash
+			}
+		}()
+	}
+
+	for i := range files {
+		// archive entries are never extracted, so they have no contents to read
+		if files[i].Archive == "" {
+			indexes <- i
+		}
+	}
+	close(indexes)
+	wg.Wait()
+	close(errChan)
+
+	return <-errChan
+}
+
+// hashFile returns the hex encoded SHA-256 of the file at path
+func hashFile(path string, buf []byte) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.CopyBuffer(h, f, buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// findDuplicates groups hashed files with identical contents, most wasted bytes first
+func findDuplicates(files []model.FileInfo) []model.DuplicateSet {
+	groups := make(map[string]*model.DuplicateSet)
+	var order []string
+	for _, f := range files {
+		if f.Hash == "" {
+			continue
+		}
+		set, ok := groups[f.Hash]
+		if !ok {
+			set = &model.DuplicateSet{Hash: f.Hash, Size: f.Size}
+			groups[f.Hash] = set
+			order = append(order, f.Hash)
+		}
+		set.Files = append(set.Files, f.Name)
+	}
+
+	var out []model.DuplicateSet
+	for _, hash := range order {
+		set := groups[hash]
+		if len(set.Files) < 2 {
+			continue
+		}
+		set.WastedBytes = set.Size * int64(len(set.Files)-1)
+		out = append(out, *set)
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		return out[i].WastedBytes > out[j].WastedBytes
+	})
+	return out
+}
@@ -0,0 +1,49 @@
+package scanner
+
+import (
+	"sort"
+
+	"github.com/babyfaceeasy/repo-scanner/internal/language"
+	"github.com/babyfaceeasy/repo-scanner/internal/model"
+)
+
+// languageTotals counts files and bytes per language
+type languageTotals struct {
+	total int64
+	langs map[string]*model.LanguageStat
+}
+
+func newLanguageTotals() *languageTotals {
+	return &languageTotals{langs: make(map[string]*model.LanguageStat)}
+}
+
+// add counts a file, given as a slash separated relative path, towards its language
+func (l *languageTotals) add(relPath string, size int64) {
+	name, kind := language.Detect(relPath)
+	stat, ok := l.langs[name]
+	if !ok {
+		stat = &model.LanguageStat{Language: name, Type: kind}
+		l.langs[name] = stat
+	}
+	stat.Files++
+	stat.Bytes += size
+	l.total += size
+}
+
+// breakdown returns every language ordered by bytes, largest first
+func (l *languageTotals) breakdown() []model.LanguageStat {
+	out := make([]model.LanguageStat, 0, len(l.langs))
+	for _, stat := range l.langs {
+		if l.total > 0 {
+			stat.Percent = float64(stat.Bytes) * 100 / float64(l.total)
+		}
+		out = append(out, *stat)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Bytes != out[j].Bytes {
+			return out[i].Bytes > out[j].Bytes
+		}
+		return out[i].Language < out[j].Language
+	})
+	return out
+}
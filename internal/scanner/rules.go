@@ -0,0 +1,55 @@
+package scanner
+
+import (
+	"path"
+	"strings"
+
+	"github.com/babyfaceeasy/repo-scanner/internal/model"
+	"github.com/babyfaceeasy/repo-scanner/internal/pathmatch"
+)
+
+// ruleSet resolves the size threshold that applies to a file
+type ruleSet struct {
+	rules            []model.SizeRule
+	mostSpecific     bool
+	defaultThreshold int64
+}
+
+// Threshold returns a function that gives the pattern of the size rule that applies to a slash
+// separated path, empty for the default, and its threshold in bytes, as ScanWithOptions uses them
+func Threshold(opts Options) func(relPath string) (string, int64) {
+	return newRuleSet(opts.Rules, opts.RulePrecedence, opts.SizeThreshold).threshold
+}
+
+func newRuleSet(rules []model.SizeRule, precedence string, defaultThreshold int64) *ruleSet {
+	return &ruleSet{
+		rules:            rules,
+		mostSpecific:     precedence == model.PrecedenceMostSpecific,
+		defaultThreshold: defaultThreshold,
+	}
+}
+
+// threshold returns the pattern of the matching rule (empty for the default) and its threshold in bytes
+func (rs *ruleSet) threshold(relPath string) (string, int64) {
+	relPath = path.Clean(strings.ReplaceAll(relPath, "\\", "/"))
+
+	best := -1
+	bestScore := -1
+	for i, r := range rs.rules {
+		if !pathmatch.Match(r.Pattern, relPath) {
+			continue
+		}
+		if !rs.mostSpecific {
+			best = i
+			break
+		}
+		if score := pathmatch.Specificity(r.Pattern); score > bestScore {
+			best, bestScore = i, score
+		}
+	}
+
+	if best < 0 {
+		return "", rs.defaultThreshold
+	}
+	return rs.rules[best].Pattern, int64(rs.rules[best].Size * 1024 * 1024)
+}
@@ -1,14 +1,48 @@
 package scanner
 
 import (
+	"errors"
 	"fmt"
 	"io/fs"
+	"math"
+	"os"
 	"path/filepath"
 
+	"github.com/babyfaceeasy/repo-scanner/internal/archive"
+	"github.com/babyfaceeasy/repo-scanner/internal/license"
+	"github.com/babyfaceeasy/repo-scanner/internal/media"
 	"github.com/babyfaceeasy/repo-scanner/internal/model"
+	"github.com/babyfaceeasy/repo-scanner/internal/secrets"
 	"github.com/babyfaceeasy/repo-scanner/pkg/logger"
 )
 
+// Options controls how a scan is performed
+type Options struct {
+	SizeThreshold     int64             // Files larger than this many bytes are reported; zero disables the check
+	Rules             []model.SizeRule  // Per-path and per-extension thresholds overriding SizeThreshold
+	RulePrecedence    string            // How to pick between several matching rules
+	Hash              bool              // Compute the SHA-256 of every reported file
+	Duplicates        bool              // Hash reported files and group identical ones
+	Categories        []string          // Only report files in these categories
+	ExcludeCategories []string          // Never report files in these categories
+	Secrets           *secrets.Detector // Search every text file for secrets when set
+	Languages         bool              // Collect bytes and file counts per language
+	Licenses          bool              // Identify LICENSE and COPYING files
+	ExcludeVendored   bool              // Leave third-party files out of threshold checks
+	ExcludeGenerated  bool              // Leave generated files out of threshold checks
+	CompressedSize    bool              // Measure the gzip and zstd compressed size of flagged files
+	Media             bool              // Decode the dimensions and duration of flagged images and videos
+	Archives          bool              // Check the entries of zip and tar archives against the thresholds
+	ArchiveDepth      int               // Levels of nested archives to open (default archive.DefaultLimits.MaxDepth)
+	TopN              int               // Collect the N largest files regardless of the threshold
+	Histogram         bool              // Collect size percentiles and a histogram of all files
+	DirDepth          int               // Roll file sizes up into directories down to this depth
+	TopDirs           int               // Number of largest directories to report
+}
+
+// defaultTopDirs is the number of directories reported when Options.TopDirs is unset
+const defaultTopDirs = 20
+
 // Scanner handles file scanning
 type Scanner struct {
 	logger logger.Logger
@@ -23,6 +57,22 @@ func New(logger logger.Logger) *Scanner {
 
 // Scan traverses the directory and finds files larger than the threshold
 func (s *Scanner) Scan(root string, sizeThreshold int64) (*model.Output, error) {
+	return s.ScanWithOptions(root, Options{SizeThreshold: sizeThreshold})
+}
+
+// ScanWithOptions traverses the directory and finds files larger than the threshold of
+// the first (or most specific) matching rule, falling back to opts.SizeThreshold
+func (s *Scanner) ScanWithOptions(root string, opts Options) (*model.Output, error) {
+	rules := newRuleSet(opts.Rules, opts.RulePrecedence, opts.SizeThreshold)
+	top := newTopFiles(opts.TopN)
+	var stats sizeStats
+	dirs := newDirTotals(opts.DirDepth)
+	langs := newLanguageTotals()
+	var licenseFiles []string
+	var secretPass *secretSearch
+	if opts.Secrets != nil {
+		secretPass = newSecretSearch(opts.Secrets)
+	}
 	var files []model.FileInfo
 	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
@@ -37,28 +87,193 @@ func (s *Scanner) Scan(root string, sizeThreshold int64) (*model.Output, error)
 			return nil // continue
 		}
 
-		s.logger.Debug("Scanning file", "path", path, "size", info.Size(), "threshold", sizeThreshold)
+		relPath, err := filepath.Rel(root, path)
+		if err != nil {
+			return fmt.Errorf("getting relative path for %s: %w", path, err)
+		}
+		rule, sizeThreshold := rules.threshold(filepath.ToSlash(relPath))
+
+		s.logger.Debug("Scanning file", "path", path, "size", info.Size(), "threshold", sizeThreshold, "rule", rule)
 
-		if info.Size() > sizeThreshold {
-			relPath, err := filepath.Rel(root, path)
+		stats.add(info.Size())
+		if opts.DirDepth > 0 {
+			dirs.add(filepath.ToSlash(relPath), info.Size())
+		}
+		if opts.Languages {
+			langs.add(filepath.ToSlash(relPath), info.Size())
+		}
+		if opts.Licenses && license.IsLicenseFile(filepath.ToSlash(relPath)) {
+			licenseFiles = append(licenseFiles, relPath)
+		}
+		if secretPass != nil {
+			secretPass.add(path, filepath.ToSlash(relPath), info.Size())
+		}
+		if opts.TopN > 0 {
+			top.add(model.FileInfo{Name: relPath, Size: info.Size()})
+		}
+		if opts.Archives && archive.IsArchive(relPath) {
+			files = append(files, s.scanArchive(path, relPath, rules, opts)...)
+		}
+
+		if sizeThreshold > 0 && info.Size() > sizeThreshold {
+			vendored := IsVendored(filepath.ToSlash(relPath))
+			generated, err := isGenerated(path, filepath.ToSlash(relPath))
 			if err != nil {
-				return fmt.Errorf("getting relative path for %s: %w", path, err)
+				return fmt.Errorf("checking whether %s is generated: %w", relPath, err)
+			}
+			if (vendored && opts.ExcludeVendored) || (generated && opts.ExcludeGenerated) {
+				s.logger.Debug("Skipping non first-party file", "path", relPath, "vendored", vendored, "generated", generated)
+				return nil
 			}
+
 			s.logger.Info("Found large file", "path", relPath, "size", info.Size())
 
 			files = append(files, model.FileInfo{
-				Name: relPath,
-				Size: info.Size(),
+				Name:      relPath,
+				Size:      info.Size(),
+				Rule:      rule,
+				Threshold: sizeThreshold,
+				Vendored:  vendored,
+				Generated: generated,
 			})
 		}
 		return nil
 	})
+	var findings []model.SecretFinding
+	if secretPass != nil {
+		var secretErr error
+		findings, secretErr = secretPass.wait()
+		if err == nil && secretErr != nil {
+			err = secretErr
+		}
+		s.logger.Info("Secrets pass completed", "findings", len(findings))
+	}
 	if err != nil {
 		return nil, fmt.Errorf("scanning directory: %w", err)
 	}
 
-	return &model.Output{
+	files, err = s.classifyFiles(root, files, newCategoryFilter(opts.Categories, opts.ExcludeCategories))
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Hash || opts.Duplicates {
+		if err := hashFiles(root, files); err != nil {
+			return nil, fmt.Errorf("hashing files: %w", err)
+		}
+		s.logger.Info("Hashed large files", "total_files", len(files))
+	}
+
+	if opts.Media {
+		if err := s.probeMedia(root, files); err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.CompressedSize {
+		if err := compressFiles(root, files); err != nil {
+			return nil, fmt.Errorf("compressing files: %w", err)
+		}
+		s.logger.Info("Measured compressed sizes", "total_files", len(files))
+	}
+
+	result := &model.Output{
 		Total: len(files),
 		Files: files,
-	}, nil
+	}
+	if opts.TopN > 0 {
+		result.Top = top.sorted()
+	}
+	if opts.Histogram {
+		result.Stats = stats.result()
+	}
+	if opts.Secrets != nil {
+		result.Secrets = findings
+	}
+	if opts.Languages {
+		result.Languages = langs.breakdown()
+	}
+	if opts.Licenses {
+		result.Licenses, err = s.identifyLicenses(root, licenseFiles)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if opts.Duplicates {
+		result.Duplicates = findDuplicates(files)
+	}
+	if opts.DirDepth > 0 {
+		topDirs := opts.TopDirs
+		if topDirs == 0 {
+			topDirs = defaultTopDirs
+		}
+		result.Directories = dirs.largest(topDirs)
+	}
+	return result, nil
+}
+
+// classifyFiles detects the MIME type and category of each file and drops those rejected by filter
+func (s *Scanner) classifyFiles(root string, files []model.FileInfo, filter *categoryFilter) ([]model.FileInfo, error) {
+	kept := files[:0]
+	for _, f := range files {
+		// archive entries are classified while the archive is read
+		if f.Archive == "" {
+			mimeType, category, binary, err := classifyFile(filepath.Join(root, f.Name))
+			if err != nil {
+				return nil, fmt.Errorf("classifying %s: %w", f.Name, err)
+			}
+			f.MIMEType, f.Category, f.Binary = mimeType, category, binary
+		}
+
+		if !filter.keep(f.Category) {
+			s.logger.Debug("Skipping file by category", "path", f.Name, "category", f.Category)
+			continue
+		}
+		kept = append(kept, f)
+	}
+	return kept, nil
+}
+
+// probeMedia decodes the headers of the images and videos among files. Files whose
+// header cannot be decoded are logged and left without media information.
+func (s *Scanner) probeMedia(root string, files []model.FileInfo) error {
+	for i, f := range files {
+		if f.Archive != "" || (f.Category != model.CategoryImage && f.Category != model.CategoryVideo) {
+			continue
+		}
+		info, err := media.Probe(filepath.Join(root, f.Name), filepath.ToSlash(f.Name))
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return fmt.Errorf("probing %s: %w", f.Name, err)
+			}
+			s.logger.Warn("Failed to decode media header", "path", f.Name, "error", err)
+			continue
+		}
+		files[i].Media = info
+	}
+	return nil
+}
+
+// identifyLicenses matches each license file against the embedded SPDX license texts
+func (s *Scanner) identifyLicenses(root string, relPaths []string) ([]model.LicenseInfo, error) {
+	licenses := make([]model.LicenseInfo, 0, len(relPaths))
+	for _, relPath := range relPaths {
+		f, err := os.Open(filepath.Join(root, relPath))
+		if err != nil {
+			return nil, fmt.Errorf("opening license %s: %w", relPath, err)
+		}
+		id, confidence, err := license.Identify(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("identifying license %s: %w", relPath, err)
+		}
+
+		s.logger.Info("Found license file", "path", relPath, "spdx_id", id, "confidence", confidence)
+		licenses = append(licenses, model.LicenseInfo{
+			Path:       filepath.ToSlash(relPath),
+			SPDXID:     id,
+			Confidence: math.Round(confidence*1000) / 1000,
+		})
+	}
+	return licenses, nil
 }
@@ -0,0 +1,98 @@
+package scanner
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/babyfaceeasy/repo-scanner/internal/model"
+	"github.com/babyfaceeasy/repo-scanner/internal/secrets"
+)
+
+const secretWorkerCount = 4
+
+// secretTask is a file queued for the secrets pass
+type secretTask struct {
+	path    string
+	relPath string
+}
+
+// secretSearch runs the secrets pass concurrently while the directory is walked
+type secretSearch struct {
+	detector *secrets.Detector
+	tasks    chan secretTask
+	wg       sync.WaitGroup
+
+	mu       sync.Mutex
+	findings []model.SecretFinding
+	err      error
+}
+
+func newSecretSearch(detector *secrets.Detector) *secretSearch {
+	ss := &secretSearch{
+		detector: detector,
+		tasks:    make(chan secretTask, 64),
+	}
+	for i := 0; i < secretWorkerCount; i++ {
+		ss.wg.Add(1)
+		go func() {
+			defer ss.wg.Done()
+			for task := range ss.tasks {
+				findings, err := ss.searchFile(task)
+				ss.mu.Lock()
+				ss.findings = append(ss.findings, findings...)
+				if err != nil && ss.err == nil {
+					ss.err = err
+				}
+				ss.mu.Unlock()
+			}
+		}()
+	}
+	return ss
+}
+
+// add queues a file, given by its absolute and slash separated relative path, unless the detector skips it
+func (ss *secretSearch) add(path, relPath string, size int64) {
+	if ss.detector.Skip(relPath, size) {
+		return
+	}
+	ss.tasks <- secretTask{path: path, relPath: relPath}
+}
+
+// wait stops accepting files and returns the findings ordered by file and line
+func (ss *secretSearch) wait() ([]model.SecretFinding, error) {
+	close(ss.tasks)
+	ss.wg.Wait()
+
+	sort.Slice(ss.findings, func(i, j int) bool {
+		if ss.findings[i].File != ss.findings[j].File {
+			return ss.findings[i].File < ss.findings[j].File
+		}
+		return ss.findings[i].Line < ss.findings[j].Line
+	})
+	return ss.findings, ss.err
+}
+
+// searchFile scans a single text file; binary files are skipped after sniffing their first bytes
+func (ss *secretSearch) searchFile(task secretTask) ([]model.SecretFinding, error) {
+	f, err := os.Open(task.path)
+	if err != nil {
+		return nil, fmt.Errorf("opening %s: %w", task.relPath, err)
+	}
+	defer f.Close()
+
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, fmt.Errorf("reading %s: %w", task.relPath, err)
+	}
+	if _, _, binary := classify(head[:n], filepath.Ext(task.path)); binary {
+		return nil, nil
+	}
+
+	return ss.detector.Scan(task.relPath, io.MultiReader(bytes.NewReader(head[:n]), f))
+}
@@ -0,0 +1,168 @@
+package scanner
+
+import (
+	"container/heap"
+	"math"
+	"math/bits"
+	"sort"
+
+	"github.com/babyfaceeasy/repo-scanner/internal/model"
+)
+
+// topFiles keeps the N largest files seen so far in a min-heap, so memory is bounded by N
+type topFiles struct {
+	limit int
+	files fileHeap
+}
+
+func newTopFiles(limit int) *topFiles {
+	return &topFiles{limit: limit}
+}
+
+func (t *topFiles) add(f model.FileInfo) {
+	if len(t.files) < t.limit {
+		heap.Push(&t.files, f)
+		return
+	}
+	if f.Size > t.files[0].Size {
+		t.files[0] = f
+		heap.Fix(&t.files, 0)
+	}
+}
+
+// sorted returns the collected files from largest to smallest
+func (t *topFiles) sorted() []model.FileInfo {
+	out := make([]model.FileInfo, len(t.files))
+	copy(out, t.files)
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Size != out[j].Size {
+			return out[i].Size > out[j].Size
+		}
+		return out[i].Name < out[j].Name
+	})
+	return out
+}
+
+type fileHeap []model.FileInfo
+
+func (h fileHeap) Len() int           { return len(h) }
+func (h fileHeap) Less(i, j int) bool { return h[i].Size < h[j].Size }
+func (h fileHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *fileHeap) Push(x any)        { *h = append(*h, x.(model.FileInfo)) }
+func (h *fileHeap) Pop() any {
+	old := *h
+	f := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return f
+}
+
+// subBucketBits controls the precision of the percentile estimates: each power of two
+// is split into 2^subBucketBits linear buckets, bounding the relative error to 1/8.
+const (
+	subBucketBits  = 3
+	subBucketCount = 1 << subBucketBits
+	bucketCount    = subBucketCount + (64-subBucketBits)*subBucketCount
+)
+
+// sizeStats accumulates file counts, total bytes and a log-scale size histogram in a
+// fixed amount of memory regardless of the number of files
+type sizeStats struct {
+	count   int64
+	total   int64
+	max     int64
+	buckets [bucketCount]int64
+}
+
+func (s *sizeStats) add(size int64) {
+	s.count++
+	s.total += size
+	if size > s.max {
+		s.max = size
+	}
+	s.buckets[bucketIndex(size)]++
+}
+
+// bucketIndex maps a size to its fine-grained bucket: sizes below subBucketCount get
+// their own bucket, larger sizes are grouped by power of two and then linearly
+func bucketIndex(size int64) int {
+	if size < subBucketCount {
+		return int(size)
+	}
+	exp := bits.Len64(uint64(size)) - 1
+	sub := int(size>>(exp-subBucketBits)) & (subBucketCount - 1)
+	return subBucketCount + (exp-subBucketBits)*subBucketCount + sub
+}
+
+// bucketBounds returns the inclusive lower and exclusive upper size of a fine-grained bucket
+func bucketBounds(idx int) (int64, int64) {
+	if idx < subBucketCount {
+		return int64(idx), int64(idx) + 1
+	}
+	exp := (idx-subBucketCount)/subBucketCount + subBucketBits
+	sub := int64((idx - subBucketCount) % subBucketCount)
+	width := int64(1) << (exp - subBucketBits)
+	lower := int64(1)<<exp + sub*width
+	return lower, lower + width
+}
+
+// percentile estimates the size below which p percent of the files fall
+func (s *sizeStats) percentile(p float64) int64 {
+	if s.count == 0 {
+		return 0
+	}
+	rank := int64(math.Ceil(p / 100 * float64(s.count)))
+	if rank < 1 {
+		rank = 1
+	}
+
+	var seen int64
+	for idx, n := range s.buckets {
+		if n == 0 {
+			continue
+		}
+		if seen+n >= rank {
+			lower, upper := bucketBounds(idx)
+			// interpolate linearly inside the bucket
+			estimate := lower + (upper-1-lower)*(rank-seen)/n
+			if estimate > s.max {
+				estimate = s.max
+			}
+			return estimate
+		}
+		seen += n
+	}
+	return s.max
+}
+
+// histogram folds the fine-grained buckets into power-of-two buckets, skipping empty ones
+func (s *sizeStats) histogram() []model.HistogramBucket {
+	var out []model.HistogramBucket
+	for idx, n := range s.buckets {
+		if n == 0 {
+			continue
+		}
+		lower, _ := bucketBounds(idx)
+		lo, hi := int64(0), int64(1)
+		if lower > 0 {
+			exp := bits.Len64(uint64(lower)) - 1
+			lo, hi = int64(1)<<exp, int64(1)<<(exp+1)
+		}
+		if len(out) > 0 && out[len(out)-1].Min == lo {
+			out[len(out)-1].Count += n
+			continue
+		}
+		out = append(out, model.HistogramBucket{Min: lo, Max: hi, Count: n})
+	}
+	return out
+}
+
+func (s *sizeStats) result() *model.Stats {
+	return &model.Stats{
+		FileCount:  s.count,
+		TotalBytes: s.total,
+		P50:        s.percentile(50),
+		P90:        s.percentile(90),
+		P99:        s.percentile(99),
+		Histogram:  s.histogram(),
+	}
+}
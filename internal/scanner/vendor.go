@@ -0,0 +1,113 @@
+package scanner
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// vendorDirs are directory names that hold third-party code
+var vendorDirs = map[string]bool{
+	"node_modules":     true,
+	"vendor":           true,
+	"third_party":      true,
+	"third-party":      true,
+	"thirdparty":       true,
+	"bower_components": true,
+	"jspm_packages":    true,
+	"Pods":             true,
+	"Carthage":         true,
+	".yarn":            true,
+}
+
+// generatedNames are file name patterns of generated files
+var generatedNames = []string{
+	"*.min.js", "*.min.css", "*.min.mjs", "*.map",
+	"*.pb.go", "*_pb2.py", "*.pb.cc", "*.pb.h",
+	"package-lock.json", "yarn.lock", "pnpm-lock.yaml", "npm-shrinkwrap.json",
+	"go.sum", "Cargo.lock", "Gemfile.lock", "composer.lock", "poetry.lock", "Pipfile.lock",
+}
+
+// generatedHeaderRe matches the markers code generators put at the top of their output,
+// including the Go convention "Code generated ... DO NOT EDIT."
+var generatedHeaderRe = regexp.MustCompile(`(?i)code generated .*do not edit|@generated|auto-?generated (?:file|code)|this file (?:was|is) (?:automatically )?generated`)
+
+// generatedHeaderLen is how much of a file is searched for a generated marker
+const generatedHeaderLen = 4 * 1024
+
+// minifiedLineLen is the line length above which .js and .css files are considered minified
+const minifiedLineLen = 1000
+
+// IsVendored reports whether a slash separated path lies in a third-party directory
+func IsVendored(relPath string) bool {
+	for _, dir := range strings.Split(path.Dir(relPath), "/") {
+		if vendorDirs[dir] {
+			return true
+		}
+	}
+	return false
+}
+
+// isGenerated reports whether a file is generated, judging by its name, its header
+// and, for scripts and stylesheets, whether it is minified
+func isGenerated(filePath, relPath string) (bool, error) {
+	if hasGeneratedName(relPath) {
+		return true, nil
+	}
+
+	f, err := os.Open(filePath)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	head := make([]byte, generatedHeaderLen)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return false, err
+	}
+	return hasGeneratedHead(relPath, head[:n]), nil
+}
+
+// hasGeneratedName reports whether the file name is one of a generated file
+func hasGeneratedName(relPath string) bool {
+	base := path.Base(relPath)
+	for _, pattern := range generatedNames {
+		if ok, _ := path.Match(pattern, base); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// hasGeneratedHead reports whether the leading bytes of a file carry a generator
+// marker or, for scripts and stylesheets, look minified
+func hasGeneratedHead(relPath string, head []byte) bool {
+	if generatedHeaderRe.Match(head) {
+		return true
+	}
+
+	switch strings.ToLower(path.Ext(relPath)) {
+	case ".js", ".mjs", ".css":
+		return bytes.IndexByte(head, 0) < 0 && longestLine(head) > minifiedLineLen
+	}
+	return false
+}
+
+func longestLine(data []byte) int {
+	longest := 0
+	for len(data) > 0 {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			i = len(data)
+		}
+		if i > longest {
+			longest = i
+		}
+		data = data[min(i+1, len(data)):]
+	}
+	return longest
+}
@@ -0,0 +1,263 @@
+package secrets
+
+import (
+	"bufio"
+	"crypto/sha256"
+	_ "embed"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/babyfaceeasy/repo-scanner/internal/model"
+	"github.com/babyfaceeasy/repo-scanner/internal/pathmatch"
+)
+
+// defaultMaxFileSize is the largest file searched for secrets when none is configured
+const defaultMaxFileSize = 5 * 1024 * 1024
+
+// maxLineLength bounds the memory used per line; only the start of longer lines (e.g. minified bundles) is searched
+const maxLineLength = 1024 * 1024
+
+//go:embed rules.json
+var defaultRules []byte
+
+// Rule describes a kind of secret. The first capture group of Regex, or the whole match
+// when there is none, is the secret itself. When Entropy is set, secrets with a lower
+// Shannon entropy (bits per character) are ignored as likely placeholders. When several
+// rules match the same text only the first one is reported.
+type Rule struct {
+	ID          string  `json:"id"`
+	Description string  `json:"description"`
+	Regex       string  `json:"regex"`
+	Entropy     float64 `json:"entropy,omitempty"`
+}
+
+// Allowlist suppresses findings by path pattern or by fingerprint
+type Allowlist struct {
+	Paths        []string `json:"paths,omitempty"`
+	Fingerprints []string `json:"fingerprints,omitempty"`
+}
+
+// RuleFile is the format of the embedded default rules and of user rule files
+type RuleFile struct {
+	Rules     []Rule    `json:"rules"`
+	Allowlist Allowlist `json:"allowlist"`
+}
+
+type compiledRule struct {
+	Rule
+	re *regexp.Regexp
+}
+
+// Detector searches file contents for secrets
+type Detector struct {
+	rules             []compiledRule
+	allowPaths        []string
+	allowFingerprints map[string]bool
+	maxFileSize       int64
+}
+
+// New builds a Detector from the embedded default rules, extended with the rules and
+// allowlist of cfg.RulesFile and the allowlists in cfg itself. A user rule with the same
+// ID as a default rule replaces it.
+func New(cfg *model.SecretsConfig) (*Detector, error) {
+	var defaults RuleFile
+	if err := json.Unmarshal(defaultRules, &defaults); err != nil {
+		return nil, fmt.Errorf("parsing default secret rules: %w", err)
+	}
+
+	rules := defaults.Rules
+	allow := Allowlist{
+		Paths:        cfg.AllowPaths,
+		Fingerprints: cfg.AllowFingerprints,
+	}
+
+	if cfg.RulesFile != "" {
+		user, err := LoadRuleFile(cfg.RulesFile)
+		if err != nil {
+			return nil, err
+		}
+		rules = mergeRules(rules, user.Rules)
+		allow.Paths = append(allow.Paths, user.Allowlist.Paths...)
+		allow.Fingerprints = append(allow.Fingerprints, user.Allowlist.Fingerprints...)
+	}
+
+	d := &Detector{
+		allowPaths:        allow.Paths,
+		allowFingerprints: make(map[string]bool, len(allow.Fingerprints)),
+		maxFileSize:       defaultMaxFileSize,
+	}
+	if cfg.MaxFileSize > 0 {
+		d.maxFileSize = int64(cfg.MaxFileSize * 1024 * 1024)
+	}
+	for _, fp := range allow.Fingerprints {
+		d.allowFingerprints[fp] = true
+	}
+	for _, r := range rules {
+		re, err := regexp.Compile(r.Regex)
+		if err != nil {
+			return nil, fmt.Errorf("compiling secret rule %s: %w", r.ID, err)
+		}
+		d.rules = append(d.rules, compiledRule{Rule: r, re: re})
+	}
+	return d, nil
+}
+
+// LoadRuleFile reads user-defined rules and allowlists from a JSON file
+func LoadRuleFile(path string) (*RuleFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading secret rules: %w", err)
+	}
+
+	var rf RuleFile
+	if err := json.Unmarshal(data, &rf); err != nil {
+		return nil, fmt.Errorf("parsing secret rules JSON: %w", err)
+	}
+	for i, r := range rf.Rules {
+		if r.ID == "" || r.Regex == "" {
+			return nil, fmt.Errorf("secret rule %d: id and regex are required", i)
+		}
+	}
+	return &rf, nil
+}
+
+// mergeRules puts user rules ahead of the defaults they do not replace, so they take
+// priority when several rules match the same text
+func mergeRules(base, extra []Rule) []Rule {
+	merged := append(make([]Rule, 0, len(base)+len(extra)), extra...)
+	overridden := make(map[string]bool, len(extra))
+	for _, r := range extra {
+		overridden[r.ID] = true
+	}
+	for _, r := range base {
+		if !overridden[r.ID] {
+			merged = append(merged, r)
+		}
+	}
+	return merged
+}
+
+// Skip reports whether a file should not be searched, because its path is allowlisted or it is too large
+func (d *Detector) Skip(relPath string, size int64) bool {
+	return size > d.maxFileSize || pathmatch.MatchAny(d.allowPaths, relPath)
+}
+
+// Scan searches r line by line and returns the secrets found in it, with redacted matches
+func (d *Detector) Scan(relPath string, r io.Reader) ([]model.SecretFinding, error) {
+	br := bufio.NewReaderSize(r, 64*1024)
+
+	var findings []model.SecretFinding
+	for lineNo := 1; ; lineNo++ {
+		line, err := readLine(br)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return findings, fmt.Errorf("reading %s: %w", relPath, err)
+		}
+		// spans already reported on this line, so broad rules do not repeat specific ones
+		var reported [][2]int
+		for _, rule := range d.rules {
+			for _, m := range rule.re.FindAllStringSubmatchIndex(line, -1) {
+				start, end := m[0], m[1]
+				if len(m) >= 4 && m[2] >= 0 {
+					start, end = m[2], m[3]
+				}
+				secret := line[start:end]
+				if rule.Entropy > 0 && entropy(secret) < rule.Entropy {
+					continue
+				}
+				if overlaps(reported, start, end) {
+					continue
+				}
+				reported = append(reported, [2]int{start, end})
+
+				fp := fingerprint(relPath, rule.ID, secret)
+				if d.allowFingerprints[fp] {
+					continue
+				}
+				findings = append(findings, model.SecretFinding{
+					File:        relPath,
+					Line:        lineNo,
+					RuleID:      rule.ID,
+					Description: rule.Description,
+					Match:       redact(secret),
+					Fingerprint: fp,
+				})
+			}
+		}
+	}
+
+	return findings, nil
+}
+
+// readLine returns the next line of br without its line ending, keeping at most maxLineLength
+// bytes of it so a long line does not stop the lines after it from being searched
+func readLine(br *bufio.Reader) (string, error) {
+	var line []byte
+	for {
+		frag, isPrefix, err := br.ReadLine()
+		if err == io.EOF && line != nil {
+			return string(line), nil
+		}
+		if err != nil {
+			return "", err
+		}
+		if room := maxLineLength - len(line); room > 0 {
+			line = append(line, frag[:min(len(frag), room)]...)
+		}
+		if !isPrefix {
+			return string(line), nil
+		}
+	}
+}
+
+func overlaps(spans [][2]int, start, end int) bool {
+	for _, s := range spans {
+		if start < s[1] && s[0] < end {
+			return true
+		}
+	}
+	return false
+}
+
+// entropy returns the Shannon entropy of s in bits per character
+func entropy(s string) float64 {
+	if s == "" {
+		return 0
+	}
+	counts := make(map[rune]int)
+	total := 0
+	for _, c := range s {
+		counts[c]++
+		total++
+	}
+
+	var e float64
+	for _, n := range counts {
+		p := float64(n) / float64(total)
+		e -= p * math.Log2(p)
+	}
+	return e
+}
+
+// fingerprint identifies a secret in a file independently of its line, so it survives edits around it
+func fingerprint(relPath, ruleID, secret string) string {
+	sum := sha256.Sum256([]byte(relPath + ":" + ruleID + ":" + secret))
+	return hex.EncodeToString(sum[:8])
+}
+
+// redact keeps just enough of a secret to recognise it
+func redact(secret string) string {
+	const visible = 4
+	if len(secret) <= visible*2 {
+		return strings.Repeat("*", len(secret))
+	}
+	return secret[:visible] + strings.Repeat("*", 8)
+}
@@ -1,15 +1,31 @@
 package service
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
 	"os"
+	"path/filepath"
 
+	"github.com/babyfaceeasy/repo-scanner/internal/baseline"
 	"github.com/babyfaceeasy/repo-scanner/internal/config"
 	"github.com/babyfaceeasy/repo-scanner/internal/github"
+	"github.com/babyfaceeasy/repo-scanner/internal/model"
 	"github.com/babyfaceeasy/repo-scanner/internal/output"
+	"github.com/babyfaceeasy/repo-scanner/internal/policy"
 	"github.com/babyfaceeasy/repo-scanner/internal/scanner"
+	"github.com/babyfaceeasy/repo-scanner/internal/secrets"
+	"github.com/babyfaceeasy/repo-scanner/internal/submodule"
 	"github.com/babyfaceeasy/repo-scanner/pkg/logger"
 )
 
+// ScanOptions holds per-run settings that come from CLI flags rather than the JSON config
+type ScanOptions struct {
+	BaselinePath  string // Baseline file to diff against, or to write when WriteBaseline is set
+	WriteBaseline bool   // Persist the result to BaselinePath instead of diffing against it
+}
+
 // Service orchestrates the application logic
 type Service struct {
 	config  *config.ConfigParser
@@ -32,30 +48,200 @@ func New(cfg *config.ConfigParser, gh github.GitHubClient, sc *scanner.Scanner,
 
 // Scan executes the repository scanning process
 func (s *Service) Scan(jsonStr string) error {
+	return s.ScanWithOptions(jsonStr, ScanOptions{})
+}
+
+// ScanWithOptions executes the repository scanning process, applying the baseline settings in opts.
+// A *policy.ViolationError is returned when the result breaks the configured policy.
+func (s *Service) ScanWithOptions(jsonStr string, opts ScanOptions) error {
+	ctx := context.Background()
 	cfg, err := s.config.Parse(jsonStr)
 	if err != nil {
 		return err
 	}
 	s.logger.Info("Config parsed", "clone_url", cfg.CloneURL, "size_mb", cfg.Size)
 
-	cloneDir, err := os.MkdirTemp("", "repo-scan-")
+	// load the baseline before downloading so a bad path fails fast
+	var base *model.Output
+	if opts.BaselinePath != "" && !opts.WriteBaseline {
+		base, err = baseline.Load(opts.BaselinePath)
+		if err != nil {
+			return err
+		}
+		s.logger.Info("Baseline loaded", "path", opts.BaselinePath, "total_files", base.Total)
+	}
+
+	var detector *secrets.Detector
+	if cfg.Secrets != nil {
+		detector, err = secrets.New(cfg.Secrets)
+		if err != nil {
+			return err
+		}
+	}
+
+	repo, err := s.preflight(ctx, cfg)
 	if err != nil {
 		return err
 	}
+	if cfg.SkipArchived && repo.Archived {
+		s.logger.Info("Skipping archived repository", "repo", repo.FullName)
+		return s.output.Write(&model.Output{Files: []model.FileInfo{}, Repository: repo, Skipped: "repository is archived"})
+	}
+
+	scanOpts := scanner.Options{
+		SizeThreshold:     int64(cfg.Size * 1024 * 1024),
+		Rules:             cfg.Rules,
+		RulePrecedence:    cfg.RulePrecedence,
+		Hash:              opts.BaselinePath != "" || opts.WriteBaseline,
+		TopN:              cfg.TopN,
+		Histogram:         cfg.Histogram,
+		DirDepth:          cfg.DirDepth,
+		TopDirs:           cfg.TopDirs,
+		Duplicates:        cfg.Duplicates,
+		Categories:        cfg.Categories,
+		ExcludeCategories: cfg.ExcludeCategories,
+		Secrets:           detector,
+		Languages:         cfg.Languages,
+		Licenses:          cfg.Licenses,
+		ExcludeVendored:   cfg.ExcludeVendored,
+		ExcludeGenerated:  cfg.ExcludeGenerated,
+		CompressedSize:    cfg.CompressedSize,
+		Media:             cfg.Media,
+		Archives:          cfg.Archives,
+		ArchiveDepth:      cfg.ArchiveDepth,
+	}
+
+	var result *model.Output
+	if cfg.Mode == model.ModeTree {
+		result, err = s.scanTree(ctx, cfg, repo, scanOpts)
+		if errors.Is(err, github.ErrTreeTruncated) {
+			s.logger.Warn("Tree too large for the Trees API, downloading the tarball instead", "repo", repo.FullName)
+		} else if err != nil {
+			return err
+		}
+	}
+	if result == nil {
+		result, err = s.scanTarball(ctx, cfg, scanOpts)
+		if err != nil {
+			return err
+		}
+	}
+	result.Repository = repo
+
+	if opts.WriteBaseline {
+		path := opts.BaselinePath
+		if path == "" {
+			path = baseline.DefaultPath
+		}
+		if err := baseline.Write(path, result); err != nil {
+			return err
+		}
+		s.logger.Info("Baseline written", "path", path, "total_files", result.Total)
+	}
+
+	if base != nil {
+		result = baseline.Diff(base, result)
+		result.Baseline = opts.BaselinePath
+		s.logger.Info("Baseline compared", "changed_files", result.Total, "regressions", result.Regressions)
+	}
+
+	if cfg.Policy != nil || base != nil {
+		result.Policy = policy.Evaluate(cfg.Policy, result)
+		for _, v := range result.Policy.Violations {
+			s.logger.Warn("Policy rule failed", "rule", v.Rule, "limit", v.Limit, "actual", v.Actual, "message", v.Message)
+		}
+	}
+
+	if err := s.output.Write(result); err != nil {
+		return err
+	}
+
+	if result.Policy != nil && !result.Policy.Passed {
+		return &policy.ViolationError{Violations: result.Policy.Violations}
+	}
+	return nil
+}
+
+// scanTarball downloads the repository, along with its submodules when asked to, and walks its files
+func (s *Service) scanTarball(ctx context.Context, cfg *model.Config, scanOpts scanner.Options) (*model.Output, error) {
+	cloneDir, err := os.MkdirTemp("", "repo-scan-")
+	if err != nil {
+		return nil, err
+	}
 	defer os.RemoveAll(cloneDir)
 	s.logger.Info("Created temp dir", "path", cloneDir)
 
-	if err := s.github.DownloadRepo(cfg.CloneURL, cloneDir); err != nil {
-		return err
+	if err := s.github.DownloadRepo(ctx, cfg.CloneURL, cloneDir); err != nil {
+		return nil, err
 	}
 	s.logger.Info("Repository downloaded", "path", cloneDir)
 
-	sizeThreshold := int64(cfg.Size * 1024 * 1024)
-	result, err := s.scanner.Scan(cloneDir, sizeThreshold)
+	// tarballs leave submodule directories empty, so their contents are fetched separately
+	depth := 0
+	if cfg.Submodules {
+		depth = cfg.SubmoduleDepth
+		if depth == 0 {
+			depth = submodule.DefaultMaxDepth
+		}
+	}
+	submodules, err := submodule.New(s.github, s.logger).Fetch(ctx, cfg.CloneURL, cloneDir, depth)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	result, err := s.scanner.ScanWithOptions(cloneDir, scanOpts)
+	if err != nil {
+		return nil, err
 	}
 	s.logger.Info("File scan completed", "total_files", result.Total)
+	result.Submodules = submodules
+	return result, nil
+}
 
-	return s.output.Write(result)
+// scanTree reports the files over their threshold from the Git Trees API without downloading them.
+// The ref of the clone URL is used, or the default branch when it names none.
+func (s *Service) scanTree(ctx context.Context, cfg *model.Config, repo *model.Repository, scanOpts scanner.Options) (*model.Output, error) {
+	repoPath, ref, err := github.ParseCloneURL(cfg.CloneURL)
+	if err != nil {
+		return nil, err
+	}
+	if ref == "" {
+		ref = repo.DefaultBranch
+	}
+
+	result, err := s.github.ScanTree(ctx, repoPath, ref, scanner.Threshold(scanOpts))
+	if err != nil {
+		return nil, err
+	}
+	// the path is all it takes to tell vendored files apart, unlike the other content tags
+	for i := range result.Files {
+		result.Files[i].Vendored = scanner.IsVendored(filepath.ToSlash(result.Files[i].Name))
+	}
+	s.logger.Info("Tree scan completed", "total_files", result.Total)
+	return result, nil
+}
+
+// preflight looks up the repository before anything is downloaded, turning access errors into
+// messages that say what is wrong and refusing repositories larger than max_repo_size
+func (s *Service) preflight(ctx context.Context, cfg *model.Config) (*model.Repository, error) {
+	repo, err := s.github.Repository(ctx, cfg.CloneURL)
+	if err != nil {
+		var notFound *github.NotFoundError
+		var unauthorized *github.UnauthorizedError
+		switch {
+		case errors.As(err, &notFound):
+			return nil, fmt.Errorf("repository %s does not exist, or is private and the token cannot see it: %w", cfg.CloneURL, err)
+		case errors.As(err, &unauthorized) && unauthorized.StatusCode == http.StatusUnauthorized:
+			return nil, fmt.Errorf("GitHub rejected the token; check GITHUB_TOKEN: %w", err)
+		case errors.As(err, &unauthorized):
+			return nil, fmt.Errorf("the token is not allowed to access %s: %w", cfg.CloneURL, err)
+		}
+		return nil, fmt.Errorf("looking up repository: %w", err)
+	}
+
+	sizeMB := float64(repo.SizeKB) / 1024
+	if cfg.MaxRepoSize > 0 && sizeMB > cfg.MaxRepoSize {
+		return nil, fmt.Errorf("repository %s is %.1f MB, larger than max_repo_size of %.1f MB", repo.FullName, sizeMB, cfg.MaxRepoSize)
+	}
+	return repo, nil
 }
@@ -2,27 +2,52 @@ package service
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/babyfaceeasy/repo-scanner/internal/config"
+	"github.com/babyfaceeasy/repo-scanner/internal/github"
 	"github.com/babyfaceeasy/repo-scanner/internal/model"
 	"github.com/babyfaceeasy/repo-scanner/internal/output"
+	"github.com/babyfaceeasy/repo-scanner/internal/policy"
 	"github.com/babyfaceeasy/repo-scanner/internal/retry"
 	"github.com/babyfaceeasy/repo-scanner/internal/scanner"
 )
 
 type mockGitHubClient struct {
-	downloadFunc func(cloneURL, destDir string) error
+	downloadFunc  func(cloneURL, destDir string) error
+	submoduleFunc func(cloneURL, path string) (string, error)
+	repoFunc      func(cloneURL string) (*model.Repository, error)
+	treeFunc      func(repo, ref string, threshold github.ThresholdFunc) (*model.Output, error)
 }
 
-func (m *mockGitHubClient) DownloadRepo(cloneURL, destDir string) error {
+func (m *mockGitHubClient) DownloadRepo(ctx context.Context, cloneURL, destDir string) error {
 	return m.downloadFunc(cloneURL, destDir)
 }
 
+func (m *mockGitHubClient) SubmoduleCommit(ctx context.Context, cloneURL, path string) (string, error) {
+	return m.submoduleFunc(cloneURL, path)
+}
+
+func (m *mockGitHubClient) Repository(ctx context.Context, cloneURL string) (*model.Repository, error) {
+	if m.repoFunc == nil {
+		return &model.Repository{FullName: "owner/repo", DefaultBranch: "main", Visibility: "public"}, nil
+	}
+	return m.repoFunc(cloneURL)
+}
+
+func (m *mockGitHubClient) ScanTree(ctx context.Context, repo, ref string, threshold github.ThresholdFunc) (*model.Output, error) {
+	return m.treeFunc(repo, ref, threshold)
+}
+
+func (m *mockGitHubClient) DiscardDownload(cloneURL string) {}
+
 type mockLogger struct {
 	logs []string
 }
@@ -131,6 +156,256 @@ func TestScan(t *testing.T) {
 	*/
 }
 
+func TestScanWithBaseline(t *testing.T) {
+	mockLog := &mockLogger{}
+	srcDir := t.TempDir()
+	createFile(t, filepath.Join(srcDir, "large.txt"), 2000)
+
+	mockGH := &mockGitHubClient{
+		downloadFunc: func(cloneURL, destDir string) error {
+			return copyDir(srcDir, destDir)
+		},
+	}
+	svc := New(config.New(), mockGH, scanner.New(mockLog), output.New(), mockLog)
+
+	input := `{"clone_url":"https://github.com/owner/repo.git","size":0.001}`
+	baselinePath := filepath.Join(t.TempDir(), "baseline.json")
+
+	captureStdout(t, func() {
+		if err := svc.ScanWithOptions(input, ScanOptions{BaselinePath: baselinePath, WriteBaseline: true}); err != nil {
+			t.Fatalf("ScanWithOptions() write baseline error = %v", err)
+		}
+	})
+
+	// unchanged repository: nothing to report and no regression
+	out := captureStdout(t, func() {
+		if err := svc.ScanWithOptions(input, ScanOptions{BaselinePath: baselinePath}); err != nil {
+			t.Fatalf("ScanWithOptions() unchanged error = %v", err)
+		}
+	})
+	if out.Total != 0 {
+		t.Errorf("unchanged Output.Total = %d, want 0", out.Total)
+	}
+
+	// a new large file is a regression
+	createFile(t, filepath.Join(srcDir, "new.bin"), 3000)
+	var scanErr error
+	out = captureStdout(t, func() {
+		scanErr = svc.ScanWithOptions(input, ScanOptions{BaselinePath: baselinePath})
+	})
+	var violationErr *policy.ViolationError
+	if !errors.As(scanErr, &violationErr) || violationErr.Violations[0].Rule != policy.RuleBaselineRegressions {
+		t.Fatalf("ScanWithOptions() error = %v, want baseline regression violation", scanErr)
+	}
+	if out.Total != 1 || out.Files[0].Name != "new.bin" || out.Files[0].Status != model.StatusNew {
+		t.Errorf("Output.Files = %v, want [new.bin (new)]", out.Files)
+	}
+}
+
+func TestScanPolicyViolation(t *testing.T) {
+	mockLog := &mockLogger{}
+	srcDir := t.TempDir()
+	createFile(t, filepath.Join(srcDir, "large.txt"), 2000)
+
+	mockGH := &mockGitHubClient{
+		downloadFunc: func(cloneURL, destDir string) error {
+			return copyDir(srcDir, destDir)
+		},
+	}
+	svc := New(config.New(), mockGH, scanner.New(mockLog), output.New(), mockLog)
+
+	var scanErr error
+	out := captureStdout(t, func() {
+		scanErr = svc.Scan(`{"clone_url":"https://github.com/owner/repo.git","size":0.001,"policy":{"max_count":0}}`)
+	})
+
+	var violationErr *policy.ViolationError
+	if !errors.As(scanErr, &violationErr) {
+		t.Fatalf("Scan() error = %v, want policy violation", scanErr)
+	}
+	if out.Policy == nil || out.Policy.Passed || out.Policy.Violations[0].Rule != policy.RuleMaxCount {
+		t.Errorf("Output.Policy = %+v, want failed %s", out.Policy, policy.RuleMaxCount)
+	}
+}
+
+func TestScanPreflight(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		repo        *model.Repository
+		repoErr     error
+		wantErr     string
+		wantSkipped string
+		wantScanned bool
+	}{
+		{
+			name:        "metadata in output",
+			input:       `{"clone_url":"https://github.com/owner/repo.git","size":0.001}`,
+			repo:        &model.Repository{FullName: "owner/repo", DefaultBranch: "main", SizeKB: 2048, Visibility: "private", Access: "pull"},
+			wantScanned: true,
+		},
+		{
+			name:    "not found",
+			input:   `{"clone_url":"https://github.com/owner/missing.git","size":0.001}`,
+			repoErr: &github.NotFoundError{URL: "https://api.github.com/repos/owner/missing"},
+			wantErr: "does not exist, or is private",
+		},
+		{
+			name:    "bad token",
+			input:   `{"clone_url":"https://github.com/owner/repo.git","size":0.001}`,
+			repoErr: &github.UnauthorizedError{StatusCode: 401},
+			wantErr: "rejected the token",
+		},
+		{
+			name:    "no access",
+			input:   `{"clone_url":"https://github.com/owner/repo.git","size":0.001}`,
+			repoErr: &github.UnauthorizedError{StatusCode: 403},
+			wantErr: "not allowed to access",
+		},
+		{
+			name:    "too large",
+			input:   `{"clone_url":"https://github.com/owner/repo.git","size":0.001,"max_repo_size":100}`,
+			repo:    &model.Repository{FullName: "owner/repo", SizeKB: 200 * 1024},
+			wantErr: "200.0 MB, larger than max_repo_size",
+		},
+		{
+			name:        "archived skipped",
+			input:       `{"clone_url":"https://github.com/owner/repo.git","size":0.001,"skip_archived":true}`,
+			repo:        &model.Repository{FullName: "owner/repo", Archived: true},
+			wantSkipped: "repository is archived",
+		},
+		{
+			name:        "archived scanned without the policy",
+			input:       `{"clone_url":"https://github.com/owner/repo.git","size":0.001}`,
+			repo:        &model.Repository{FullName: "owner/repo", Archived: true},
+			wantScanned: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockLog := &mockLogger{}
+			srcDir := t.TempDir()
+			createFile(t, filepath.Join(srcDir, "large.txt"), 2000)
+
+			downloaded := false
+			mockGH := &mockGitHubClient{
+				downloadFunc: func(cloneURL, destDir string) error {
+					downloaded = true
+					return copyDir(srcDir, destDir)
+				},
+				repoFunc: func(cloneURL string) (*model.Repository, error) {
+					return tt.repo, tt.repoErr
+				},
+			}
+			svc := New(config.New(), mockGH, scanner.New(mockLog), output.New(), mockLog)
+
+			if tt.wantErr != "" {
+				err := svc.Scan(tt.input)
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Scan() error = %v, want one containing %q", err, tt.wantErr)
+				}
+				if downloaded {
+					t.Error("repository downloaded despite failing the pre-flight check")
+				}
+				return
+			}
+
+			var scanErr error
+			out := captureStdout(t, func() { scanErr = svc.Scan(tt.input) })
+			if scanErr != nil {
+				t.Fatalf("Scan() error = %v", scanErr)
+			}
+			if downloaded != tt.wantScanned {
+				t.Errorf("downloaded = %v, want %v", downloaded, tt.wantScanned)
+			}
+			if out.Skipped != tt.wantSkipped {
+				t.Errorf("Output.Skipped = %q, want %q", out.Skipped, tt.wantSkipped)
+			}
+			if out.Repository == nil || *out.Repository != *tt.repo {
+				t.Errorf("Output.Repository = %+v, want %+v", out.Repository, tt.repo)
+			}
+		})
+	}
+}
+
+func TestScanTreeMode(t *testing.T) {
+	for _, truncated := range []bool{false, true} {
+		mockLog := &mockLogger{}
+		srcDir := t.TempDir()
+		createFile(t, filepath.Join(srcDir, "large.txt"), 2000)
+
+		downloaded := false
+		var treeRef string
+		mockGH := &mockGitHubClient{
+			downloadFunc: func(cloneURL, destDir string) error {
+				downloaded = true
+				return copyDir(srcDir, destDir)
+			},
+			submoduleFunc: func(cloneURL, path string) (string, error) {
+				return "", errors.New("no submodules")
+			},
+			treeFunc: func(repo, ref string, threshold github.ThresholdFunc) (*model.Output, error) {
+				treeRef = ref
+				if truncated {
+					return nil, github.ErrTreeTruncated
+				}
+				rule, limit := threshold("assets/logo.png")
+				return &model.Output{Total: 2, Files: []model.FileInfo{
+					{Name: "assets/logo.png", Size: 5000, Rule: rule, Threshold: limit},
+					{Name: "vendor/lib/blob.bin", Size: 5000},
+				}}, nil
+			},
+		}
+		svc := New(config.New(), mockGH, scanner.New(mockLog), output.New(), mockLog)
+
+		var scanErr error
+		out := captureStdout(t, func() {
+			scanErr = svc.Scan(`{"clone_url":"https://github.com/owner/repo.git","size":0.001,"mode":"tree","rules":[{"pattern":"assets/**","size":0.002}]}`)
+		})
+		if scanErr != nil {
+			t.Fatalf("Scan() truncated=%v error = %v", truncated, scanErr)
+		}
+		if treeRef != "main" {
+			t.Errorf("tree requested at %q, want the default branch", treeRef)
+		}
+		if downloaded != truncated {
+			t.Errorf("truncated=%v: downloaded = %v", truncated, downloaded)
+		}
+		if out.Repository == nil || out.Total == 0 {
+			t.Fatalf("truncated=%v: Output = %+v, want files with repository metadata", truncated, out)
+		}
+		if truncated {
+			if out.Total != 1 || out.Files[0].Name != "large.txt" {
+				t.Errorf("fallback Output.Files = %+v, want large.txt from the tarball", out.Files)
+			}
+		} else if f := out.Files[0]; out.Total != 2 || f.Rule != "assets/**" || f.Threshold != 2097 || f.Vendored || !out.Files[1].Vendored {
+			t.Errorf("tree Output.Files = %+v, want the assets/** rule applied and the vendor/ file tagged", out.Files)
+		}
+	}
+}
+
+// captureStdout runs fn and decodes the JSON it writes to stdout
+func captureStdout(t *testing.T, fn func()) model.Output {
+	t.Helper()
+	oldStdout := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+
+	fn()
+
+	w.Close()
+	os.Stdout = oldStdout
+	var buf bytes.Buffer
+	buf.ReadFrom(r)
+
+	var got model.Output
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("Failed to parse output JSON: %v", err)
+	}
+	return got
+}
+
 func createFile(t *testing.T, path string, size int64) {
 	t.Helper()
 	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
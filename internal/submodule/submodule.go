@@ -0,0 +1,213 @@
+package submodule
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/babyfaceeasy/repo-scanner/internal/github"
+	"github.com/babyfaceeasy/repo-scanner/internal/model"
+	"github.com/babyfaceeasy/repo-scanner/pkg/logger"
+)
+
+// DefaultMaxDepth is how many levels of nested submodules are downloaded when no depth is configured
+const DefaultMaxDepth = 3
+
+// Entry is a submodule declared in a .gitmodules file
+type Entry struct {
+	Name string
+	Path string
+	URL  string
+}
+
+// Parse reads the submodules declared in a .gitmodules file, sorted by path
+func Parse(r io.Reader) ([]Entry, error) {
+	var entries []Entry
+	var current *Entry
+
+	sc := bufio.NewScanner(r)
+	for line := 1; sc.Scan(); line++ {
+		text := strings.TrimSpace(sc.Text())
+		if text == "" || text[0] == '#' || text[0] == ';' {
+			continue
+		}
+
+		if strings.HasPrefix(text, "[") {
+			current = nil
+			section, ok := strings.CutSuffix(text, "]")
+			if !ok {
+				return nil, fmt.Errorf("line %d: malformed section header", line)
+			}
+			kind, name, _ := strings.Cut(strings.TrimPrefix(section, "["), " ")
+			if kind == "submodule" {
+				entries = append(entries, Entry{Name: strings.Trim(strings.TrimSpace(name), `"`)})
+				current = &entries[len(entries)-1]
+			}
+			continue
+		}
+
+		if current == nil {
+			continue
+		}
+		key, value, ok := strings.Cut(text, "=")
+		if !ok {
+			continue
+		}
+		value = strings.Trim(strings.TrimSpace(value), `"`)
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "path":
+			current.Path = value
+		case "url":
+			current.URL = value
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+
+	valid := entries[:0]
+	for _, e := range entries {
+		if e.Path != "" && e.URL != "" {
+			valid = append(valid, e)
+		}
+	}
+	sort.Slice(valid, func(i, j int) bool { return valid[i].Path < valid[j].Path })
+	return valid, nil
+}
+
+// ResolveURL converts a submodule URL into a GitHub HTTPS clone URL. Relative URLs
+// are resolved against the clone URL of the repository that declares the submodule.
+func ResolveURL(parentCloneURL, rawURL string) (string, error) {
+	var repo string
+	switch {
+	case strings.HasPrefix(rawURL, "./") || strings.HasPrefix(rawURL, "../"):
+		parent, _, err := github.ParseCloneURL(parentCloneURL)
+		if err != nil {
+			return "", err
+		}
+		repo = path.Join(parent, rawURL)
+	case strings.HasPrefix(rawURL, "git@github.com:"):
+		repo = strings.TrimPrefix(rawURL, "git@github.com:")
+	default:
+		for _, prefix := range []string{"https://github.com/", "http://github.com/", "git://github.com/", "ssh://git@github.com/"} {
+			if strings.HasPrefix(rawURL, prefix) {
+				repo = strings.TrimPrefix(rawURL, prefix)
+				break
+			}
+		}
+	}
+
+	repo = strings.TrimSuffix(strings.TrimSuffix(repo, "/"), ".git")
+	if strings.Count(repo, "/") != 1 || strings.HasPrefix(repo, ".") {
+		return "", fmt.Errorf("not a GitHub repository")
+	}
+	return "https://github.com/" + repo + ".git", nil
+}
+
+// Fetcher downloads submodule contents into an extracted repository
+type Fetcher struct {
+	github github.GitHubClient
+	logger logger.Logger
+}
+
+// New creates a new Fetcher
+func New(gh github.GitHubClient, logger logger.Logger) *Fetcher {
+	return &Fetcher{github: gh, logger: logger}
+}
+
+// Fetch reports the submodules of the repository extracted in dir. When maxDepth is positive,
+// each GitHub-hosted submodule is downloaded at its pinned commit into its path, recursing
+// into nested submodules up to maxDepth levels, so a single scan of dir covers them all.
+// Submodules that cannot be fetched are reported with the reason rather than failing.
+func (f *Fetcher) Fetch(ctx context.Context, cloneURL, dir string, maxDepth int) ([]model.Submodule, error) {
+	repo, _, err := github.ParseCloneURL(cloneURL)
+	if err != nil {
+		return nil, err
+	}
+	return f.fetch(ctx, cloneURL, dir, "", 1, maxDepth, map[string]bool{strings.ToLower(repo): true})
+}
+
+func (f *Fetcher) fetch(ctx context.Context, cloneURL, dir, prefix string, depth, maxDepth int, ancestors map[string]bool) ([]model.Submodule, error) {
+	file, err := os.Open(filepath.Join(dir, ".gitmodules"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("opening .gitmodules: %w", err)
+	}
+	entries, err := Parse(file)
+	file.Close()
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path.Join(prefix, ".gitmodules"), err)
+	}
+
+	var result []model.Submodule
+	for _, e := range entries {
+		sub := model.Submodule{Name: e.Name, Path: path.Join(prefix, e.Path), URL: e.URL}
+
+		if !filepath.IsLocal(e.Path) {
+			sub.Skipped = "path outside the repository"
+			result = append(result, sub)
+			continue
+		}
+		if maxDepth == 0 {
+			result = append(result, sub)
+			continue
+		}
+		if depth > maxDepth {
+			sub.Skipped = "depth limit reached"
+			result = append(result, sub)
+			continue
+		}
+
+		subURL, err := ResolveURL(cloneURL, e.URL)
+		if err != nil {
+			sub.Skipped = err.Error()
+			result = append(result, sub)
+			continue
+		}
+		subRepo, _, _ := github.ParseCloneURL(subURL)
+		if ancestors[strings.ToLower(subRepo)] {
+			sub.Skipped = "cycle detected"
+			f.logger.Warn("Submodule cycle detected", "path", sub.Path, "repo", subRepo)
+			result = append(result, sub)
+			continue
+		}
+
+		sub.Commit, err = f.github.SubmoduleCommit(ctx, cloneURL, e.Path)
+		if err != nil {
+			sub.Skipped = err.Error()
+			f.logger.Warn("Failed to resolve submodule commit", "path", sub.Path, "error", err)
+			result = append(result, sub)
+			continue
+		}
+
+		pinnedURL := fmt.Sprintf("https://github.com/%s/tree/%s", subRepo, sub.Commit)
+		subDir := filepath.Join(dir, filepath.FromSlash(e.Path))
+		if err := f.github.DownloadRepo(ctx, pinnedURL, subDir); err != nil {
+			sub.Skipped = err.Error()
+			f.logger.Warn("Failed to download submodule", "path", sub.Path, "error", err)
+			result = append(result, sub)
+			continue
+		}
+		sub.Scanned = true
+		f.logger.Info("Submodule downloaded", "path", sub.Path, "repo", subRepo, "commit", sub.Commit)
+		result = append(result, sub)
+
+		ancestors[strings.ToLower(subRepo)] = true
+		nested, err := f.fetch(ctx, pinnedURL, subDir, sub.Path, depth+1, maxDepth, ancestors)
+		delete(ancestors, strings.ToLower(subRepo))
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, nested...)
+	}
+	return result, nil
+}
@@ -0,0 +1,238 @@
+package submodule
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/babyfaceeasy/repo-scanner/internal/github"
+	"github.com/babyfaceeasy/repo-scanner/internal/model"
+)
+
+type mockGitHubClient struct {
+	downloadFunc  func(cloneURL, destDir string) error
+	submoduleFunc func(cloneURL, path string) (string, error)
+	repoFunc      func(cloneURL string) (*model.Repository, error)
+	treeFunc      func(repo, ref string, threshold github.ThresholdFunc) (*model.Output, error)
+}
+
+func (m *mockGitHubClient) DownloadRepo(ctx context.Context, cloneURL, destDir string) error {
+	return m.downloadFunc(cloneURL, destDir)
+}
+
+func (m *mockGitHubClient) SubmoduleCommit(ctx context.Context, cloneURL, path string) (string, error) {
+	return m.submoduleFunc(cloneURL, path)
+}
+
+func (m *mockGitHubClient) Repository(ctx context.Context, cloneURL string) (*model.Repository, error) {
+	return m.repoFunc(cloneURL)
+}
+
+func (m *mockGitHubClient) ScanTree(ctx context.Context, repo, ref string, threshold github.ThresholdFunc) (*model.Output, error) {
+	return m.treeFunc(repo, ref, threshold)
+}
+
+func (m *mockGitHubClient) DiscardDownload(cloneURL string) {}
+
+type mockLogger struct {
+	logs []string
+}
+
+func (m *mockLogger) Info(msg string, fields ...interface{})  { m.logs = append(m.logs, msg) }
+func (m *mockLogger) Error(msg string, fields ...interface{}) { m.logs = append(m.logs, msg) }
+func (m *mockLogger) Warn(msg string, fields ...interface{})  { m.logs = append(m.logs, msg) }
+func (m *mockLogger) Debug(msg string, fields ...interface{}) { m.logs = append(m.logs, msg) }
+func (m *mockLogger) Fatal(msg string, fields ...interface{}) { m.logs = append(m.logs, msg) }
+
+func TestParse(t *testing.T) {
+	input := `# comment
+[submodule "libs/b"]
+	path = libs/b
+	url = git@github.com:owner/b.git
+[core]
+	path = ignored
+[submodule "a"]
+	path = "third_party/a"
+	url = ../a.git
+	branch = main
+[submodule "incomplete"]
+	url = https://github.com/owner/c.git
+`
+	entries, err := Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	want := []Entry{
+		{Name: "libs/b", Path: "libs/b", URL: "git@github.com:owner/b.git"},
+		{Name: "a", Path: "third_party/a", URL: "../a.git"},
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("Parse() = %+v, want %+v", entries, want)
+	}
+	for i := range want {
+		if entries[i] != want[i] {
+			t.Errorf("entries[%d] = %+v, want %+v", i, entries[i], want[i])
+		}
+	}
+
+	if _, err := Parse(strings.NewReader("[submodule \"x\"\n")); err == nil {
+		t.Error("Parse() expected error for malformed section header")
+	}
+}
+
+func TestResolveURL(t *testing.T) {
+	tests := []struct {
+		name    string
+		rawURL  string
+		want    string
+		wantErr bool
+	}{
+		{name: "https", rawURL: "https://github.com/other/lib.git", want: "https://github.com/other/lib.git"},
+		{name: "https without .git", rawURL: "https://github.com/other/lib", want: "https://github.com/other/lib.git"},
+		{name: "scp-like ssh", rawURL: "git@github.com:other/lib.git", want: "https://github.com/other/lib.git"},
+		{name: "ssh", rawURL: "ssh://git@github.com/other/lib.git", want: "https://github.com/other/lib.git"},
+		{name: "relative", rawURL: "../lib.git", want: "https://github.com/owner/lib.git"},
+		{name: "relative to another owner", rawURL: "../../other/lib.git", want: "https://github.com/other/lib.git"},
+		{name: "other host", rawURL: "https://gitlab.com/other/lib.git", wantErr: true},
+		{name: "relative escaping github", rawURL: "../../../lib.git", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ResolveURL("https://github.com/owner/repo/tree/main", tt.rawURL)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ResolveURL() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ResolveURL() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFetch(t *testing.T) {
+	root := t.TempDir()
+	writeGitmodules(t, root, map[string]string{
+		"libs/a":   "https://github.com/owner/a.git",
+		"libs/ext": "https://gitlab.com/owner/ext.git",
+		"libs/bad": "https://github.com/owner/private.git",
+	})
+
+	// a contains b, and b points back at the root repository
+	trees := map[string]map[string]string{
+		"owner/a": {"libs/b": "../b.git"},
+		"owner/b": {"loop": "https://github.com/owner/repo.git", "deeper": "../c.git"},
+	}
+
+	var downloads []string
+	gh := &mockGitHubClient{
+		submoduleFunc: func(cloneURL, path string) (string, error) {
+			if path == "libs/bad" {
+				return "", errors.New("unexpected status code: 404")
+			}
+			return "sha-" + filepath.Base(path), nil
+		},
+		downloadFunc: func(cloneURL, destDir string) error {
+			downloads = append(downloads, cloneURL)
+			repo := strings.TrimPrefix(cloneURL, "https://github.com/")
+			repo = repo[:strings.Index(repo, "/tree/")]
+			if err := os.MkdirAll(destDir, 0o755); err != nil {
+				return err
+			}
+			if subs, ok := trees[repo]; ok {
+				writeGitmodules(t, destDir, subs)
+			}
+			return os.WriteFile(filepath.Join(destDir, "file.txt"), []byte(repo), 0o644)
+		},
+	}
+
+	got, err := New(gh, &mockLogger{}).Fetch(context.Background(), "https://github.com/owner/repo.git", root, 2)
+	if err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+
+	type result struct {
+		scanned bool
+		skipped string
+	}
+	want := map[string]result{
+		"libs/a":               {scanned: true},
+		"libs/a/libs/b":        {scanned: true},
+		"libs/a/libs/b/deeper": {skipped: "depth limit reached"},
+		"libs/a/libs/b/loop":   {skipped: "depth limit reached"},
+		"libs/bad":             {skipped: "unexpected status code: 404"},
+		"libs/ext":             {skipped: "not a GitHub repository"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Fetch() = %+v, want %d submodules", got, len(want))
+	}
+	for _, sub := range got {
+		w, ok := want[sub.Path]
+		if !ok {
+			t.Errorf("unexpected submodule %s", sub.Path)
+			continue
+		}
+		if sub.Scanned != w.scanned || sub.Skipped != w.skipped {
+			t.Errorf("%s: scanned=%v skipped=%q, want scanned=%v skipped=%q", sub.Path, sub.Scanned, sub.Skipped, w.scanned, w.skipped)
+		}
+	}
+
+	wantDownloads := []string{"https://github.com/owner/a/tree/sha-a", "https://github.com/owner/b/tree/sha-b"}
+	if strings.Join(downloads, ",") != strings.Join(wantDownloads, ",") {
+		t.Errorf("downloads = %v, want %v", downloads, wantDownloads)
+	}
+	if _, err := os.Stat(filepath.Join(root, "libs/a/libs/b/file.txt")); err != nil {
+		t.Errorf("nested submodule not extracted under its path: %v", err)
+	}
+
+	// with a deeper limit the loop back to the root repository is caught as a cycle
+	root = t.TempDir()
+	writeGitmodules(t, root, map[string]string{"libs/a": "https://github.com/owner/a.git"})
+	downloads = nil
+	got, err = New(gh, &mockLogger{}).Fetch(context.Background(), "https://github.com/owner/repo.git", root, 5)
+	if err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	for _, sub := range got {
+		if sub.Path == "libs/a/libs/b/loop" && sub.Skipped != "cycle detected" {
+			t.Errorf("loop: skipped = %q, want cycle detected", sub.Skipped)
+		}
+	}
+}
+
+func TestFetchReportOnly(t *testing.T) {
+	root := t.TempDir()
+	writeGitmodules(t, root, map[string]string{"libs/a": "https://github.com/owner/a.git"})
+
+	gh := &mockGitHubClient{
+		downloadFunc:  func(string, string) error { t.Fatal("unexpected download"); return nil },
+		submoduleFunc: func(string, string) (string, error) { t.Fatal("unexpected lookup"); return "", nil },
+	}
+	got, err := New(gh, &mockLogger{}).Fetch(context.Background(), "https://github.com/owner/repo.git", root, 0)
+	if err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	if len(got) != 1 || got[0].Path != "libs/a" || got[0].Scanned || got[0].Skipped != "" {
+		t.Errorf("Fetch() = %+v, want libs/a reported only", got)
+	}
+
+	got, err = New(gh, &mockLogger{}).Fetch(context.Background(), "https://github.com/owner/repo.git", t.TempDir(), 0)
+	if err != nil || got != nil {
+		t.Errorf("Fetch() without .gitmodules = %+v, %v, want nil", got, err)
+	}
+}
+
+func writeGitmodules(t *testing.T, dir string, subs map[string]string) {
+	t.Helper()
+	var b strings.Builder
+	for path, url := range subs {
+		b.WriteString("[submodule \"" + path + "\"]\n\tpath = " + path + "\n\turl = " + url + "\n")
+	}
+	if err := os.WriteFile(filepath.Join(dir, ".gitmodules"), []byte(b.String()), 0o644); err != nil {
+		t.Fatalf("Failed to write .gitmodules: %v", err)
+	}
+}